@@ -15,6 +15,8 @@
  */
 'use strict';
 var util = require('util');
+var fs = require('fs');
+var path = require('path');
 var helper = require('./helper.js');
 var logger = helper.getLogger('install-chaincode');
 
@@ -37,6 +39,12 @@ var installChaincode = async function(peers, chaincodeName, chaincodePath,
 			chaincodeVersion: chaincodeVersion,
 			chaincodeType: chaincodeType
 		};
+		// package the CouchDB index definitions when the chaincode has them
+		var metadataPath = path.join(process.env.GOPATH, 'src', chaincodePath, 'META-INF');
+		if (fs.existsSync(metadataPath)) {
+			logger.debug('Packaging chaincode metadata from "%s"', metadataPath);
+			request.metadataPath = metadataPath;
+		}
 		let results = await client.installChaincode(request);
 		// the returned object has both the endorsement results
 		// and the actual proposal, the proposal will be needed
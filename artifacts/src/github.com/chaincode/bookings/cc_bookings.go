@@ -7,7 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/chaincode/domain"
 	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 )
@@ -18,37 +20,162 @@ var logger = shim.NewLogger("Chaincode for Movie Bookings")
 type BookingChaincode struct {
 }
 
-var strFlag = "ExchangeFlagData"
+// Composite key object type of the Water to Soda exchanges asked for, one key per Theater ID, date, booking time and
+// Booking ID. A booking only writes its own key without reading the others, so bookings never conflict on the quota.
+var beverageConsumptionObject = "beverageConsumption"
 
-type BookingDetails struct {
-	BookedByUser     string    `json:"bookedByUser"`
-	MovieName        string    `json:"movieName"`
-	TimeSlot         string    `json:"timeSlot"`
-	ReqNmbrOfTickets int       `json:"reqNmbrOfTickets"`
-	BookingId        string    `json:"bookingId"`
-	SeatDetails      []SeatDetails    `json:"seatDetails"`
-    BookingTime string `json:"bookingTime"`
+// Composite key object type of the configured daily quota, one key per Theater ID
+var beverageQuotaConfigObject = "beverageQuotaConfig"
+
+// Daily Water to Soda exchange quota of the theaters without a configured quota
+var defaultDailyBeverageQuota = 200
+
+// Date format of the daily quota keys
+var quotaDateFormat = "2006-01-02"
+
+// Theater of the bookings made before shows had a theater, as the Movies chaincode defaults it
+var defaultTheaterId = "DEFAULT"
+
+// Key of the chaincode configuration written by Init
+var bookingConfigKey = "bookingChaincodeConfig"
+
+// Movies chaincode called when there is no configuration
+var defaultMoviesChaincode = "cc_movies"
+
+// Certificate attribute Fabric CA puts the enrollment ID in
+var enrollmentIdAttribute = "hf.EnrollmentID"
+
+// Fields of the bookings queryBookings can filter on, and their kinds. The META-INF CouchDB indexes cover these queries.
+var bookingQueryFields = map[string]string{
+	"ownerId":          domain.StringField,
+	"showId":           domain.StringField,
+	"bookedByUser":     domain.StringField,
+	"movieName":        domain.StringField,
+	"timeSlot":         domain.StringField,
+	"theaterId":        domain.StringField,
+	"bookingStatus":    domain.StringField,
+	"reqNmbrOfTickets": domain.NumberField,
+	"bookingTime":      domain.TimeField}
+
+// Largest page of bookings queryBookings returns
+var maxBookingPageSize = 100
+
+// Composite key object type of the seat holds, one key per Hold ID
+var seatHoldObject = "seatHold"
+
+// Composite key index over expiry time and Hold ID of the open holds, walked in expiry order by sweepExpiredHolds
+var holdExpiryIndex = "indexHoldExpiry"
+
+// How long seats stay held for checkout before anyone else can book them
+var holdDuration = 5 * time.Minute
+
+// Fixed width UTC time format of the time ordered indexes, so that their keys sort in time order
+var sortableTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Composite key object type of the waitlist entries, one key per Entry ID
+var waitlistEntryObject = "waitlistEntry"
+
+// Composite key index over Movie ID, Time slot, join time and Entry ID of the waiting entries, the FIFO queue of a show
+var showWaitlistIndex = "indexShowWaitlist"
+
+// Composite key index over Movie ID, Time slot and Booking ID of the bookings, used to find every booking of a show
+// when it is cancelled
+var showBookingIndex = "indexShowBooking"
+
+// Promoted waitlist entries get a longer hold than checkout, the customer has to be notified first
+var waitlistHoldDuration = 15 * time.Minute
+
+// BookingConfig - Configuration of the chaincode, the Movies chaincode every seat inventory and show call goes to
+// and the channel it is deployed on. Identities of TheaterAdminMSP are theater admins, as in the Movies chaincode.
+// ShowBookingsIndexed records that the bookings made before the indexShowBooking index have been indexed.
+type BookingConfig struct {
+	MoviesChaincode     string `json:"moviesChaincode"`
+	Channel             string `json:"channel"`
+	TheaterAdminMSP     string `json:"theaterAdminMSP"`
+	ShowBookingsIndexed bool   `json:"showBookingsIndexed"`
+}
+
+// BookingPage - A page of queryBookings. Bookmark is passed back to fetch the next page, the last page is the one
+// with fewer records fetched than the page size.
+type BookingPage struct {
+	Bookings            []domain.Booking `json:"bookings"`
+	Bookmark            string           `json:"bookmark"`
+	FetchedRecordsCount int              `json:"fetchedRecordsCount"`
+}
+
+// SeatHold - Seats held for a User during checkout. HoldStatus is Held until the hold is Confirmed into a Booking,
+// Released by the User or Expired by sweepExpiredHolds; the prices of the held seats and the show's theater are kept
+// for the Booking.
+type SeatHold struct {
+	HoldId       string      `json:"holdId"`
+	HeldByUser   string      `json:"heldByUser"`
+	OwnerId      string      `json:"ownerId"`
+	ShowId       string      `json:"showId"`
+	MovieName    string      `json:"movieName"`
+	TimeSlot     string      `json:"timeSlot"`
+	TheaterId    string      `json:"theaterId"`
+	Seats        []domain.SeatQuote `json:"seats"`
+	TotalPrice   int         `json:"totalPrice"`
+	Currency     string      `json:"currency"`
+	HoldTime     string      `json:"holdTime"`
+	ExpiryTime   string      `json:"expiryTime"`
+	HoldStatus   string      `json:"holdStatus"`
+	BookingId    string      `json:"bookingId"`
+}
+
+// WaitlistEntry - A User waiting for seats of a house-full show. EntryStatus is Waiting until the entry is Promoted
+// to a Hold of the freed seats or the User leaves the waitlist (Left).
+type WaitlistEntry struct {
+	EntryId          string `json:"entryId"`
+	WaitingUser      string `json:"waitingUser"`
+	OwnerId          string `json:"ownerId"`
+	ShowId           string `json:"showId"`
+	MovieName        string `json:"movieName"`
+	TimeSlot         string `json:"timeSlot"`
+	ReqNmbrOfTickets int    `json:"reqNmbrOfTickets"`
+	JoinTime         string `json:"joinTime"`
+	EntryStatus      string `json:"entryStatus"`
+	HoldId           string `json:"holdId"`
+}
+
+// BeverageQuotaConfig - Daily Water to Soda exchange quota of a theater
+type BeverageQuotaConfig struct {
+	TheaterId  string `json:"theaterId"`
+	DailyQuota int    `json:"dailyQuota"`
+}
+
+// BeverageConsumption - Water to Soda exchanges a Booking asked for from a theater's daily quota
+type BeverageConsumption struct {
+	TheaterId   string `json:"theaterId"`
+	Date        string `json:"date"`
+	BookingTime string `json:"bookingTime"`
+	BookingId   string `json:"bookingId"`
+	Exchanges   int    `json:"exchanges"`
 }
 
-type SeatDetails struct {
-    SeatNumber    string     `json:"seatNumber"`
-	ReceiptNumber string    `json:"receiptNumber"`
-    BeverageFlag  string    `json:"beverageFlag"`
-    WaterToSodaExchangeFlag string `json:"waterToSodaExchangeFlag"`
+// BeverageQuota - Water to Soda exchanges of a theater on a date, added up from the consumption records of the date.
+// The quota covers the Requested exchanges in booking order up to the daily quota, Consumed. The records are kept
+// per date, so the quota rolls over by itself when the date changes.
+type BeverageQuota struct {
+	TheaterId  string `json:"theaterId"`
+	Date       string `json:"date"`
+	DailyQuota int    `json:"dailyQuota"`
+	Requested  int    `json:"requested"`
+	Consumed   int    `json:"consumed"`
+	Remaining  int    `json:"remaining"`
 }
 
-type DatewiseBeverageExchangeDetails struct {
-    Date string `json:"date"`
-    DailyQuota string `json:"dailyQuota"`
+// caller - Identity submitting the transaction
+type caller struct {
+	OwnerId      string
+	EnrollmentId string
+	Role         string
 }
 
-type movie struct {
-	MovieName          string    `json:"movieName"`
-	AvailalbeTimeSlots string    `json:"availalbeTimeSlots"`
-	TotalTickets       int       `json:"totalTickets"`
-	RemainingTickets   int       `json:"remainingTickets"`
-	HouseFullFlag      string    `json:"houseFullFlag"`
-	ModificationTime   time.Time `json:"modificationTime"`
+// customer - Owner and name of a booking, hold or waitlist entry
+type customer struct {
+	OwnerId string
+	Name    string
 }
 
 // ===================================================================================
@@ -61,18 +188,56 @@ func main() {
 	}
 }
 
-// Init initializes chaincode
+// Init initializes chaincode. Optional args are the Movies chaincode name, its channel and the theater admin MSP ID.
 // ===========================
 func (t *BookingChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
-    
-    // Initializing the Water to Soda exchange flag
-    current_time := time.Now()
-    date := string(current_time.Format("2006-January-02"))
-    dailyQuota := strconv.Itoa(200)
-    datewiseBeverageExchangeDetails := DatewiseBeverageExchangeDetails{Date: date, DailyQuota: dailyQuota}
-    datewiseBeverageExchangeBytes, _ := json.Marshal(datewiseBeverageExchangeDetails)
-    stub.PutState(strFlag, datewiseBeverageExchangeBytes)
-    
+
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) > 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting optionally the Movies chaincode name, channel and theater admin MSP ID")
+	}
+
+	configAsBytes, err := stub.GetState(bookingConfigKey)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	config := &BookingConfig{MoviesChaincode: defaultMoviesChaincode, Channel: stub.GetChannelID()}
+	if configAsBytes != nil {
+		var existingConfig BookingConfig
+		err = json.Unmarshal(configAsBytes, &existingConfig)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		// An upgrade without args keeps the configuration it finds
+		if len(args) == 0 {
+			config = &existingConfig
+		}
+		config.ShowBookingsIndexed = existingConfig.ShowBookingsIndexed
+	}
+	if len(args) > 0 && args[0] != "" {
+		config.MoviesChaincode = args[0]
+	}
+	if len(args) > 1 && args[1] != "" {
+		config.Channel = args[1]
+	}
+	if len(args) > 2 {
+		config.TheaterAdminMSP = args[2]
+	}
+
+	// The bookings made before the indexShowBooking index are indexed once, by the first upgrade that finds them
+	if !config.ShowBookingsIndexed {
+		err = indexShowBookings(stub)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		config.ShowBookingsIndexed = true
+	}
+
+	err = putBookingConfig(stub, config)
+	if err != nil {
+		return domain.Failed(err)
+	}
 	return shim.Success(nil)
 }
 
@@ -87,218 +252,2052 @@ func (t *BookingChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response
 	// Handle different functions
 	if function == "initBookingDetails" { // Making Booking Details for Users
 		return t.initBookingDetails(stub, args)
+	} else if function == "initBookingWithSeats" { // Making Booking Details for Users with selected Seat Numbers
+		return t.initBookingWithSeats(stub, args)
 	} else if function == "getShowDetailsByTimeSlot" { // Get the Details according to the TimeSlot
 		return t.getShowDetailsByTimeSlot(stub, args)
+	} else if function == "getBookingById" { // Get the Booking Details for a Booking ID
+		return t.getBookingById(stub, args)
+	} else if function == "getBookingHistory" { // Get every version of a Booking
+		return t.getBookingHistory(stub, args)
+	} else if function == "getBookingsByUser" { // Get all the Bookings made by a User
+		return t.getBookingsByUser(stub, args)
+	} else if function == "getQuote" { // Get the price of a booking before making it
+		return t.getQuote(stub, args)
+	} else if function == "holdSeats" { // Hold seats of a show for a User during checkout
+		return t.holdSeats(stub, args)
+	} else if function == "confirmHold" { // Turn a Hold into a Booking
+		return t.confirmHold(stub, args)
+	} else if function == "releaseHold" { // Give the seats of a Hold back before it expires
+		return t.releaseHold(stub, args)
+	} else if function == "getHold" { // Get the details of a Hold
+		return t.getHold(stub, args)
+	} else if function == "sweepExpiredHolds" { // Release the seats of every expired Hold
+		return t.sweepExpiredHolds(stub, args)
+	} else if function == "joinWaitlist" { // Queue a User for seats of a house-full show
+		return t.joinWaitlist(stub, args)
+	} else if function == "leaveWaitlist" { // Take a User off the waitlist of a show
+		return t.leaveWaitlist(stub, args)
+	} else if function == "getWaitlist" { // Get the waiting entries of a show in FIFO order
+		return t.getWaitlist(stub, args)
+	} else if function == "promoteWaitlist" { // Offer the free seats of a show to its waitlist
+		return t.promoteWaitlist(stub, args)
+	} else if function == "redeemBeverageExchange" { // Redeem the Water to Soda exchange of a Receipt at the concession stand
+		return t.redeemBeverageExchange(stub, args)
+	} else if function == "getBeverageQuota" { // Get the Water to Soda exchange quota of a theater for a date
+		return t.getBeverageQuota(stub, args)
+	} else if function == "setBeverageQuota" { // Configure the daily Water to Soda exchange quota of a theater
+		return t.setBeverageQuota(stub, args)
+	} else if function == "cancelBooking" { // Cancel a Booking and release the seats back to the show
+		return t.cancelBooking(stub, args)
+	} else if function == "transferBooking" { // Hand a Booking over to another customer
+		return t.transferBooking(stub, args)
+	} else if function == "queryBookings" { // Find Bookings with a CouchDB rich query
+		return t.queryBookings(stub, args)
+	} else if function == "setConfig" { // Point the chaincode at another Movies chaincode or channel
+		return t.setConfig(stub, args)
+	} else if function == "markShowRefundDue" { // Mark the Bookings of a cancelled show for refund, called by cancelShow
+		return t.markShowRefundDue(stub, args)
 	}
 
 	fmt.Println("invoke did not find func: " + function) //error
-	return shim.Error("Received unknown function invocation")
+	return domain.ErrorResponse(domain.CodeInvalidArgument, "Received unknown function invocation")
 }
 
-// initBookingDetails - Creating record Movie name, time slots and total ticket for a show
+// initBookingDetails - Books the requested number of tickets for a show, taking the first free seats of the show.
+// Args are User, Show ID and Number of Tickets.
 func (t *BookingChaincode) initBookingDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
 	logger.Info("########### START - initBookingDetails ###########")
 
 	var err error
-	if len(args) != 4 {
-		return shim.Error("Incorrect number of arguments. Expecting 4")
-    }
+	if len(args) != 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting User, Show ID and Number of Tickets")
+	}
 
 	// Params for Ticket Bookings
-	bookedByUser := args[0]
-	movieName := args[1]
-	timeSlot := args[2]
-	reqNmbrOfTickets, err := strconv.Atoi(args[3])
+	bookedBy, err := customerFor(stub, args[0])
+	if err != nil {
+		return unauthorized("initBookingDetails", err)
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[1])
 	if err != nil {
-		return shim.Error("Expecting an integer value for Booking Number of Tickets")
+		return domain.Failed(err)
+	}
+	reqNmbrOfTickets, err := strconv.Atoi(args[2])
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting an integer value for Booking Number of Tickets")
+	}
+	if reqNmbrOfTickets <= 0 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Booking Number of Tickets must be greater than zero")
 	}
-	bookingId := bookedByUser + "_" + strconv.FormatInt(time.Now().Unix(), 10)
 
-	logger.Info("Booking Details: ", bookedByUser, movieName, timeSlot, reqNmbrOfTickets)
+	return t.bookShow(stub, bookedBy, movieId, timeSlot, reqNmbrOfTickets, []string{})
+}
 
-	// ---- CALLING MOVIES CHAINCODE TO CHECK AVAILABILITY ---- //
-	chainCodeArgs := util.ToChaincodeArgs("getMoviesByName", movieName)
-	response := stub.InvokeChaincode("cc_movies", chainCodeArgs, "mychannel")
-	var m movie
-	json.Unmarshal(response.Payload, &m)
-	// logger.Info("Chaincode Response: ", m)
-
-	resMovieName := m.MovieName
-	resTimeSlots := m.AvailalbeTimeSlots
-	resTotalTicketsInt := m.TotalTickets
-	resRemainingTickets := m.RemainingTickets
-	resHouseFullFlag := m.HouseFullFlag
-
-	logger.Info("Output of existing movie: ", resMovieName, resTimeSlots, resTotalTicketsInt, resRemainingTickets, resHouseFullFlag)
-
-	// ---- Verify following before booking tickets for user
-	// 1. Requested movie exists
-	// 2. Booking available for the requested time slot
-	// 3. Movie is not housefull yet
-	if strings.ToUpper(resMovieName) == strings.ToUpper(movieName) && resTimeSlots == timeSlot && strings.ToUpper(resHouseFullFlag) == "FALSE" {
-
-		remainingTicketsInt := resRemainingTickets - reqNmbrOfTickets
-		remainingTicketsStr := strconv.Itoa(remainingTicketsInt)
-		resTotalTicketsStr := strconv.Itoa(resTotalTicketsInt)
-
-		// Check whether seats available and remaining seats are greater then booked seat, book the seats for user.
-		if resRemainingTickets > 0 && resRemainingTickets > reqNmbrOfTickets {
-
-            // Creating list of SeatNumber, Receipts and Beverage Flag
-			seatDetailsList := []SeatDetails{}
-            i := 0
-            var waterToSodaExchangeFlag string
-			for i < reqNmbrOfTickets {
-                seatNumber := strconv.Itoa(i)
-                currTime := time.Now()
-                currDateStr := string(currTime.Format("2006-January-02"))
-				receiptNumber := strconv.FormatInt(currTime.Unix(), 10)
-                beverageFlag := "True"
-
-                // Fetching data for Soda/Water exchange
-                remainingValueForDateBytes, _ := stub.GetState(strFlag)
-                var data DatewiseBeverageExchangeDetails
-                json.Unmarshal(remainingValueForDateBytes, &data)
-                date := data.Date
-                dailyQuota, _ := strconv.Atoi(data.DailyQuota)
-
-                if dailyQuota > 0 && date == currDateStr {
-                    waterToSodaExchangeFlag = "True"
-                    // dailyQuotaNewVal := dailyQuota - 1
-                    dailyQuotaNewVal := dailyQuota - reqNmbrOfTickets
-                    exchangeCountRemaining := strconv.Itoa(dailyQuotaNewVal)
-                    newData := DatewiseBeverageExchangeDetails{Date: date, DailyQuota: exchangeCountRemaining}
-                    newDataBytes, _ := json.Marshal(newData)
-                    stub.PutState(strFlag, newDataBytes)
-                } else {
-                    waterToSodaExchangeFlag = "False"
-                }
-
-                // Putting data into DatewiseBeverageExchangeDetails for next date
-                if dailyQuota == 0 && date == currDateStr {
-                    nextDay := currTime.AddDate(0, 0, 1)
-                    date := string(nextDay.Format("2006-January-02"))
-                    dailyQuota := strconv.Itoa(200)
-                    datewiseBeverageExchangeDetails := DatewiseBeverageExchangeDetails{Date: date, DailyQuota: dailyQuota}
-                    datewiseBeverageExchangeBytes, _ := json.Marshal(datewiseBeverageExchangeDetails)
-                    stub.PutState(strFlag, datewiseBeverageExchangeBytes)
-                }
-
-				fmt.Println("Receipt ID: ", receiptNumber)
-				fmt.Println("Seat Number: ", seatNumber)
-				seatDetailsObj := SeatDetails{SeatNumber: seatNumber, ReceiptNumber: receiptNumber, BeverageFlag: beverageFlag, WaterToSodaExchangeFlag: waterToSodaExchangeFlag}
-				seatDetailsList = append(seatDetailsList, seatDetailsObj)
-				i = i + 1
-            }
-            
-            currTime := time.Now()
-            bookingTime := currTime.Format(time.RFC3339Nano)
-
-			BookingDetailsObj := BookingDetails{
-				BookedByUser:     bookedByUser,
-				MovieName:        movieName,
-				TimeSlot:         timeSlot,
-				ReqNmbrOfTickets: reqNmbrOfTickets,
-				BookingId:        bookingId,
-				SeatDetails:      seatDetailsList,
-				BookingTime:      bookingTime }
-
-			bookingDetailsAsBytes, _ := json.Marshal(BookingDetailsObj)
-			err = stub.PutState(bookedByUser, bookingDetailsAsBytes)
-			if err != nil {
-				return shim.Error(err.Error())
-			}
+// initBookingWithSeats - Books the requested Seat Numbers of a show, the booking is rejected if any of them is already taken.
+// Args are User, Show ID and the Seat Numbers.
+func (t *BookingChaincode) initBookingWithSeats(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-			// Updating the Movie data -- calling initMovieDetails after show book for user
-			chainCodeArgs := util.ToChaincodeArgs("initMovieDetails", resMovieName, resTimeSlots, resTotalTicketsStr, remainingTicketsStr, resHouseFullFlag)
-			response := stub.InvokeChaincode("cc_movies", chainCodeArgs, "mychannel")
+	logger.Info("########### START - initBookingWithSeats ###########")
 
-			if response.Status != shim.OK {
-				return shim.Error(response.Message)
-			}
+	if len(args) < 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting User, Show ID and at least one Seat Number")
+	}
 
-			eventMessage := "{ \"message\" : \"Movie show booked succcessfully\", \"Booking ID\" : \"" + bookingId + "\", \"code\" : \"200\"}"
-			err = stub.SetEvent("evtsender", []byte(eventMessage))
-			if err != nil {
-				return shim.Error(err.Error())
-			}
+	// Params for Ticket Bookings
+	bookedBy, err := customerFor(stub, args[0])
+	if err != nil {
+		return unauthorized("initBookingWithSeats", err)
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[1])
+	if err != nil {
+		return domain.Failed(err)
+	}
+	requestedSeats := args[2:]
 
-            msg := "Show booked successfully. Booking ID: " + bookingId
-            logger.Info(msg)
-			return shim.Success([]byte(msg))
+	seenSeats := map[string]bool{}
+	for _, seatNumber := range requestedSeats {
+		if seenSeats[seatNumber] {
+			return domain.ErrorResponse(domain.CodeInvalidArgument, "Seat " + seatNumber + " is requested more than once")
+		}
+		seenSeats[seatNumber] = true
+	}
 
-		} else if resRemainingTickets < reqNmbrOfTickets { // ELSE - Check if the requested number of seats are available for booking or not
+	return t.bookShow(stub, bookedBy, movieId, timeSlot, len(requestedSeats), requestedSeats)
+}
 
-			var remTickets int
-			if remainingTicketsInt != 0 && remainingTicketsInt < 0 {
-				remTickets = remainingTicketsInt * -1
-			} else {
-				remTickets = remainingTicketsInt
-			}
+// bookShow - Reserves the seats in the show's seat inventory through the Movies chaincode and writes the Booking.
+// Everything happens in the calling transaction, so either all the seats are booked or none. The show's ticket
+// counts are not read: reading them adds up every booking of the show, which would make concurrent bookings of
+// the same show conflict.
+func (t *BookingChaincode) bookShow(stub shim.ChaincodeStubInterface, bookedBy *customer, movieId string, timeSlot string, reqNmbrOfTickets int, requestedSeats []string) pb.Response {
 
-			remTicketsStr := strconv.Itoa(remTickets)
+	// Booking ID, Receipt Numbers and Booking Time come from the transaction so that every endorser writes the same values
+	bookingId := stub.GetTxID()
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
 
-			eventMessage := "{ \"Available Tickets\" : \"" + remTicketsStr + "\", \"message\" : \"Only limited seats are available.\", \"code\" : \"200\"}"
-			err = stub.SetEvent("evtsender", []byte(eventMessage))
-			if err != nil {
-				return shim.Error(err.Error())
-			}
+	logger.Info("Booking Details: ", bookedBy.Name, movieId, timeSlot, reqNmbrOfTickets)
 
-            msg := "Only limited seats are available. Remaning Seat: " + remTicketsStr
-            logger.Info(msg)
-			return shim.Success([]byte(msg))
+	// ---- CALLING MOVIES CHAINCODE TO RESERVE THE SEATS ---- //
+	reserveArgs := append([]string{"reserveShowSeats", movieId, timeSlot, bookingId, strconv.Itoa(reqNmbrOfTickets)}, requestedSeats...)
+	reserveResponse := invokeMovies(stub, util.ToChaincodeArgs(reserveArgs...))
+	if reserveResponse.Status == shim.OK {
+		var reservedQuote domain.ShowQuote
+		err = json.Unmarshal(reserveResponse.Payload, &reservedQuote)
+		if err != nil {
+			return domain.Failed(err)
+		}
 
-		} else if remainingTicketsInt == 0 { // ELSE - when there is zero seats available.
+		return writeBooking(stub, bookedBy, bookingId, currTime, &reservedQuote)
+	}
 
-			houseFullFlag := "True"
+	// The Movies chaincode tells why the seats could not be booked: NOT_FOUND, SOLD_OUT, INSUFFICIENT_SEATS or CONFLICT
+	// when a requested seat is taken
+	return domain.Failed(moviesError(reserveResponse))
+}
 
-			chainCodeArgs := util.ToChaincodeArgs("initMovieDetails", resMovieName, resTimeSlots, resTotalTicketsStr, remainingTicketsStr, houseFullFlag)
-			response := stub.InvokeChaincode("cc_movies", chainCodeArgs, "mychannel")
+// writeBooking - Writes a Booking for seats already booked in the show's seat inventory, issuing a Receipt Number
+// per seat and asking for the Water to Soda exchange of every seat from the theater's quota of the day
+func writeBooking(stub shim.ChaincodeStubInterface, bookedBy *customer, bookingId string, currTime time.Time, quote *domain.ShowQuote) pb.Response {
 
-			if response.Status != shim.OK {
-				return shim.Error(response.Message)
-			}
+	theaterId := quote.TheaterId
+	if theaterId == "" {
+		theaterId = defaultTheaterId
+	}
 
-			eventMessage := "{ \"message\" : \"Movie show is not available for Booking\", \"code\" : \"200\"}"
-			err = stub.SetEvent("evtsender", []byte(eventMessage))
-			if err != nil {
-				return shim.Error(err.Error())
-			}
+	// Creating list of SeatNumber, Receipts and Beverage Flag
+	seatDetailsList := []domain.SeatDetails{}
+	for i, quotedSeat := range quote.Seats {
+		seatNumber := quotedSeat.SeatNumber
+		receiptNumber := bookingId + "_" + strconv.Itoa(i)
+		beverageFlag := "True"
+		waterToSodaExchangeFlag := "True"
 
-            msg := "Selected time slot for " + movieName + " is housefull already."
-            logger.Info(msg)
-			return shim.Success([]byte(msg))
-		}
-	} else {
-        msg := "Requested movie is not available for booking."
-        logger.Info(msg)
-		return shim.Success([]byte(msg))
+		fmt.Println("Receipt ID: ", receiptNumber)
+		fmt.Println("Seat Number: ", seatNumber)
+		seatDetailsObj := domain.SeatDetails{SeatNumber: seatNumber, ReceiptNumber: receiptNumber, BeverageFlag: beverageFlag, WaterToSodaExchangeFlag: waterToSodaExchangeFlag, Category: quotedSeat.Category, Price: quotedSeat.Price, BeverageRedeemedFlag: "False"}
+		seatDetailsList = append(seatDetailsList, seatDetailsObj)
 	}
 
-	fmt.Println("- end Movie Booking request")
+	bookingTime := currTime.Format(time.RFC3339)
 
-	return shim.Success(nil)
+	BookingDetailsObj := domain.Booking{
+		BookedByUser:     bookedBy.Name,
+		OwnerId:          bookedBy.OwnerId,
+		ShowId:           quote.ShowId,
+		MovieName:        quote.MovieName,
+		TimeSlot:         quote.TimeSlot,
+		ReqNmbrOfTickets: len(quote.Seats),
+		BookingId:        bookingId,
+		SeatDetails:      seatDetailsList,
+		BookingTime:      bookingTime,
+		BookingStatus:    "Booked",
+		TotalPrice:       quote.TotalPrice,
+		Currency:         quote.Currency,
+		TheaterId:        theaterId }
+
+	err := putBooking(stub, &BookingDetailsObj)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	// The quota of the day covers the exchanges in booking order, which is settled when they are redeemed
+	consumption, err := bookingConsumption(&BookingDetailsObj)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = putBeverageConsumption(stub, consumption)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = domain.SetEvent(stub, &domain.Event{Message: "Movie show booked succcessfully", BookingId: bookingId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Show booked successfully. Booking ID: " + bookingId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
 }
 
 func (t *BookingChaincode) getShowDetailsByTimeSlot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 
-	var timeSlot, jsonResp string
+	var timeSlot string
 	var err error
 
 	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting Time Slot to fetch the details")
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Time Slot to fetch the details")
 	}
 	timeSlot = args[0]
 
 	valAsbytes, err := stub.GetState(timeSlot) //get the Incident details from chaincode state
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for given TimeSlot" + timeSlot + "\"}"
-		return shim.Error(jsonResp)
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for given TimeSlot" + timeSlot)
 	} else if valAsbytes == nil {
-		jsonResp = "{\"Error\":\"No Movie show is running for the requested time slot: " + timeSlot + "\"}"
-		return shim.Error(jsonResp)
+		return domain.ErrorResponse(domain.CodeNotFound, "No Movie show is running for the requested time slot: " + timeSlot)
 	}
 
 	return shim.Success(valAsbytes)
 }
+
+// getQuote - Price of a prospective booking. Args are Show ID, Number of Tickets and optionally the Seat Numbers,
+// the quote covers the same seats initBookingDetails or initBookingWithSeats would book right now.
+func (t *BookingChaincode) getQuote(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) < 2 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID, Number of Tickets and optionally Seat Numbers")
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[0])
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	chainCodeArgs := util.ToChaincodeArgs(append([]string{"quoteShowSeats", movieId, timeSlot}, args[1:]...)...)
+	response := invokeMovies(stub, chainCodeArgs)
+	if response.Status != shim.OK {
+		return domain.Failed(moviesError(response))
+	}
+
+	return shim.Success(response.Payload)
+}
+
+// cancelBooking - Cancels a Booking, releasing its seats and the Water to Soda exchange quota it used
+func (t *BookingChaincode) cancelBooking(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - cancelBooking ###########")
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Booking ID to cancel")
+	}
+	bookingId := args[0]
+
+	bookingAsBytes, err := stub.GetState(bookingId)
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for given Booking ID " + bookingId)
+	} else if bookingAsBytes == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Booking found for the requested Booking ID: " + bookingId)
+	}
+
+	var booking domain.Booking
+	err = json.Unmarshal(bookingAsBytes, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = checkOwner(stub, booking.OwnerId)
+	if err != nil {
+		return unauthorized("cancelBooking", err)
+	}
+
+	if booking.BookingStatus == "Cancelled" {
+		return domain.ErrorResponse(domain.CodeConflict, "Booking " + bookingId + " is already cancelled")
+	} else if booking.BookingStatus == "RefundDue" {
+		return domain.ErrorResponse(domain.CodeConflict, "Booking " + bookingId + " is due a refund, its show was cancelled")
+	}
+
+	// ---- CALLING MOVIES CHAINCODE TO RETURN THE SEATS ---- //
+	movieId, timeSlot := showKeyParts(booking.ShowId, booking.MovieName, booking.TimeSlot)
+	releaseArgs := []string{"releaseShowSeats", movieId, timeSlot, bookingId}
+	for _, seatDetails := range booking.SeatDetails {
+		releaseArgs = append(releaseArgs, seatDetails.SeatNumber)
+	}
+	response := invokeMovies(stub, util.ToChaincodeArgs(releaseArgs...))
+	if response.Status != shim.OK {
+		return domain.Failed(moviesError(response))
+	}
+
+	// Giving back the Water to Soda exchanges that were not redeemed to the quota of the booking date
+	err = returnBeverageQuota(stub, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	for i := range booking.SeatDetails {
+		if booking.SeatDetails[i].BeverageRedeemedFlag != "True" {
+			booking.SeatDetails[i].WaterToSodaExchangeFlag = "False"
+		}
+	}
+
+	booking.BookingStatus = "Cancelled"
+	err = putBooking(stub, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	// The freed seats are offered to the waitlist by promoteWaitlist for the Show ID of the event
+	err = domain.SetEvent(stub, &domain.Event{Message: "Movie show booking cancelled succcessfully", BookingId: bookingId, ShowId: domain.ShowKey(movieId, timeSlot)})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Booking cancelled successfully. Booking ID: " + bookingId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
+}
+
+// transferBooking - Hands a Booking over to another customer. Args are Booking ID, the new owner's MSP ID and enrollment
+// ID, and optionally the customer name to book under, the enrollment ID by default.
+func (t *BookingChaincode) transferBooking(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - transferBooking ###########")
+
+	if len(args) != 3 && len(args) != 4 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Booking ID, new owner's MSP ID, enrollment ID and optionally name")
+	}
+	bookingId := args[0]
+	newOwnerMSP := args[1]
+	newOwnerEnrollmentId := args[2]
+	if newOwnerMSP == "" || newOwnerEnrollmentId == "" {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "New owner's MSP ID and enrollment ID must not be empty")
+	}
+	newName := newOwnerEnrollmentId
+	if len(args) == 4 && args[3] != "" {
+		newName = args[3]
+	}
+
+	bookingAsBytes, err := stub.GetState(bookingId)
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for given Booking ID " + bookingId)
+	} else if bookingAsBytes == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Booking found for the requested Booking ID: " + bookingId)
+	}
+
+	var booking domain.Booking
+	err = json.Unmarshal(bookingAsBytes, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = checkOwner(stub, booking.OwnerId)
+	if err != nil {
+		return unauthorized("transferBooking", err)
+	}
+
+	if booking.BookingStatus == "Cancelled" || booking.BookingStatus == "RefundDue" {
+		return domain.ErrorResponse(domain.CodeConflict, "Booking " + bookingId + " is cancelled and cannot be transferred")
+	}
+
+	// Taking the Booking off the index of its previous owner
+	previousOwnerId := booking.OwnerId
+	previousIndexKey, err := domain.UserBookingKey(stub, previousOwnerId, bookingId)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = stub.DelState(previousIndexKey)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	booking.OwnerId = newOwnerMSP + "/" + newOwnerEnrollmentId
+	booking.BookedByUser = newName
+	err = putBooking(stub, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = domain.SetEvent(stub, &domain.Event{Message: "Movie show booking transferred succcessfully", BookingId: bookingId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Booking " + bookingId + " transferred from " + previousOwnerId + " to " + booking.OwnerId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
+}
+
+// setConfig - Changes the Movies chaincode name and channel, for deployments that move the Movies chaincode, and
+// optionally the theater admin MSP ID. Only theater admins can change them.
+func (t *BookingChaincode) setConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - setConfig ###########")
+
+	if len(args) != 2 && len(args) != 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movies chaincode name, channel and optionally the theater admin MSP ID")
+	}
+	if args[0] == "" || args[1] == "" {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Movies chaincode name and channel must not be empty")
+	}
+
+	err := checkStaff(stub, domain.TheaterAdminRole)
+	if err != nil {
+		return unauthorized("setConfig", err)
+	}
+
+	config, err := getBookingConfig(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	config.MoviesChaincode = args[0]
+	config.Channel = args[1]
+	if len(args) == 3 {
+		config.TheaterAdminMSP = args[2]
+	}
+	err = putBookingConfig(stub, config)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	logger.Info("Configuration saved: ", config.MoviesChaincode, " on ", config.Channel)
+	return shim.Success(nil)
+}
+
+// markShowRefundDue - Marks every Booked booking of a show RefundDue and returns them. Args are Movie ID and Time slot.
+// Only cancelShow of the Movies chaincode calls it, after checking the caller is a theater admin.
+func (t *BookingChaincode) markShowRefundDue(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - markShowRefundDue ###########")
+
+	if len(args) != 2 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID and Time slot")
+	}
+	if !invokedThroughMovies(stub) {
+		return unauthorized("markShowRefundDue", fmt.Errorf("bookings are only marked for refund by cancelShow of the Movies chaincode"))
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(showBookingIndex, []string{args[0], args[1]})
+	if err != nil {
+		return domain.Failed(err)
+	}
+	defer resultsIterator.Close()
+
+	refunds := []domain.RefundDueBooking{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		bookingAsBytes, err := stub.GetState(compositeKeyParts[2])
+		if err != nil {
+			return domain.Failed(err)
+		} else if bookingAsBytes == nil {
+			return domain.ErrorResponse(domain.CodeInternal, "No Booking found for the Booking ID " + compositeKeyParts[2] + " indexed against the show")
+		}
+		booking, err := domain.UnmarshalBooking(bookingAsBytes)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		if booking.BookingStatus != "Booked" {
+			continue
+		}
+
+		booking.BookingStatus = "RefundDue"
+		err = putBooking(stub, booking)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		refunds = append(refunds, domain.RefundDueBooking{
+			BookingId:        booking.BookingId,
+			OwnerId:          booking.OwnerId,
+			BookedByUser:     booking.BookedByUser,
+			ReqNmbrOfTickets: booking.ReqNmbrOfTickets,
+			TotalPrice:       booking.TotalPrice,
+			Currency:         booking.Currency})
+	}
+
+	refundsAsBytes, err := json.Marshal(refunds)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	return shim.Success(refundsAsBytes)
+}
+
+// getBookingConfig - Configuration written by Init, cc_movies on the channel of the transaction when there is none
+func getBookingConfig(stub shim.ChaincodeStubInterface) (*BookingConfig, error) {
+	config := &BookingConfig{MoviesChaincode: defaultMoviesChaincode, Channel: stub.GetChannelID()}
+
+	configAsBytes, err := stub.GetState(bookingConfigKey)
+	if err != nil {
+		return nil, err
+	} else if configAsBytes != nil {
+		err = json.Unmarshal(configAsBytes, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// putBookingConfig - Writes the configuration
+func putBookingConfig(stub shim.ChaincodeStubInterface, config *BookingConfig) error {
+	configAsBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(bookingConfigKey, configAsBytes)
+}
+
+// moviesError - Error of a failed call to the Movies chaincode, see domain.UpstreamError
+func moviesError(response pb.Response) *domain.ChaincodeError {
+	return domain.UpstreamError("Movies chaincode", response)
+}
+
+// invokeMovies - Calls the configured Movies chaincode within this transaction
+func invokeMovies(stub shim.ChaincodeStubInterface, chainCodeArgs [][]byte) pb.Response {
+	config, err := getBookingConfig(stub)
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to read the chaincode configuration: " + err.Error())
+	}
+	return stub.InvokeChaincode(config.MoviesChaincode, chainCodeArgs, config.Channel)
+}
+
+// getCaller - Identity submitting the transaction, taken from its certificate. The Owner ID is the MSP ID and the
+// enrollment ID, which is the hf.EnrollmentID attribute of Fabric CA certificates and the certificate's ID otherwise.
+func getCaller(stub shim.ChaincodeStubInterface) (*caller, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the MSP ID of the caller: %s", err)
+	}
+	enrollmentId, found, err := cid.GetAttributeValue(stub, enrollmentIdAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the enrollment ID of the caller: %s", err)
+	}
+	if !found || enrollmentId == "" {
+		enrollmentId, err = cid.GetID(stub)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the ID of the caller: %s", err)
+		}
+	}
+	config, err := getBookingConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	role, err := domain.CallerRole(stub, config.TheaterAdminMSP)
+	if err != nil {
+		return nil, err
+	}
+	return &caller{OwnerId: mspId + "/" + enrollmentId, EnrollmentId: enrollmentId, Role: role}, nil
+}
+
+// isBoxOffice - Whether the caller can book for and manage the bookings of other customers
+func (c *caller) isBoxOffice() bool {
+	return c.Role == domain.BoxOfficeRole || c.Role == domain.TheaterAdminRole
+}
+
+// customerFor - Owner and name of a booking requested with the given customer name. Customers book for themselves,
+// the name must be empty or their enrollment ID; box-office staff book walk-in customers under their own identity.
+func customerFor(stub shim.ChaincodeStubInterface, name string) (*customer, error) {
+	currCaller, err := getCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name == currCaller.EnrollmentId {
+		return &customer{OwnerId: currCaller.OwnerId, Name: currCaller.EnrollmentId}, nil
+	}
+	if !currCaller.isBoxOffice() {
+		return nil, fmt.Errorf("%s can only book for itself, not for %s", currCaller.OwnerId, name)
+	}
+	return &customer{OwnerId: currCaller.OwnerId, Name: name}, nil
+}
+
+// checkOwner - Errors unless the caller is the given owner or box-office staff
+func checkOwner(stub shim.ChaincodeStubInterface, ownerId string) error {
+	currCaller, err := getCaller(stub)
+	if err != nil {
+		return err
+	}
+	if currCaller.OwnerId != ownerId && !currCaller.isBoxOffice() {
+		return fmt.Errorf("%s is not the owner", currCaller.OwnerId)
+	}
+	return nil
+}
+
+// checkStaff - Errors unless the caller has one of the given roles, see domain.CheckStaff
+func checkStaff(stub shim.ChaincodeStubInterface, roles ...string) error {
+	config, err := getBookingConfig(stub)
+	if err != nil {
+		return err
+	}
+	return domain.CheckStaff(stub, config.TheaterAdminMSP, roles...)
+}
+
+// unauthorized - Response to a caller that is not allowed to call the function
+func unauthorized(function string, err error) pb.Response {
+	return domain.ErrorResponse(domain.CodeUnauthorized, "Not allowed to call "+function+": "+err.Error())
+}
+
+// invokedThroughMovies - Whether the transaction proposal was sent to the Movies chaincode, which is the case when
+// cancelShow of the Movies chaincode calls this one
+func invokedThroughMovies(stub shim.ChaincodeStubInterface) bool {
+	config, err := getBookingConfig(stub)
+	if err != nil {
+		return false
+	}
+	return domain.InvokedThrough(stub, config.MoviesChaincode)
+}
+
+// showKeyParts - Movie ID and Time slot of the show of a Booking, Hold or Waitlist entry in the Movies chaincode.
+// Records written before they carried a Show ID name the show by its Movie name, which legacy shows are keyed by.
+func showKeyParts(showId string, movieName string, timeSlot string) (string, string) {
+	movieId, showTimeSlot, err := domain.ParseShowId(showId)
+	if err != nil {
+		return movieName, timeSlot
+	}
+	return movieId, showTimeSlot
+}
+
+// txTime - Transaction timestamp as time.Time, used for every date and time written by this chaincode
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), nil
+}
+
+// putBooking - Writes the booking under its Booking ID and indexes it against its Owner ID and its show
+func putBooking(stub shim.ChaincodeStubInterface, booking *domain.Booking) error {
+	booking.DocType = domain.BookingDocType
+
+	bookingDetailsAsBytes, err := json.Marshal(booking)
+	if err != nil {
+		return err
+	}
+
+	err = stub.PutState(booking.BookingId, bookingDetailsAsBytes)
+	if err != nil {
+		return err
+	}
+
+	// Create Index
+	userBookingIndexKey, err := domain.UserBookingKey(stub, booking.OwnerId, booking.BookingId)
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	err = stub.PutState(userBookingIndexKey, value)
+	if err != nil {
+		return err
+	}
+
+	return putShowBookingIndex(stub, booking)
+}
+
+// showBookingKey - Key of a booking in the index of the bookings of its show
+func showBookingKey(stub shim.ChaincodeStubInterface, booking *domain.Booking) (string, error) {
+	movieId, timeSlot := showKeyParts(booking.ShowId, booking.MovieName, booking.TimeSlot)
+	return stub.CreateCompositeKey(showBookingIndex, []string{movieId, timeSlot, booking.BookingId})
+}
+
+// putShowBookingIndex - Indexes a booking against its show
+func putShowBookingIndex(stub shim.ChaincodeStubInterface, booking *domain.Booking) error {
+	showBookingIndexKey, err := showBookingKey(stub, booking)
+	if err != nil {
+		return err
+	}
+	value := []byte{0x00}
+	return stub.PutState(showBookingIndexKey, value)
+}
+
+// indexShowBookings - Adds the bookings made before the indexShowBooking index to it, walking the indexUserBooking
+// index, so that markShowRefundDue finds every booking of a show. Bookings confirmed from a Hold were once written
+// without their Show ID; it is taken back from the Hold and the booking is moved to the index entry of its show.
+// It runs once, from Init, and reads every booking in that transaction.
+func indexShowBookings(stub shim.ChaincodeStubInterface) error {
+
+	// Show IDs of the Holds confirmed into Bookings
+	heldShowIds := make(map[string]string)
+	holdsIterator, err := stub.GetStateByPartialCompositeKey(seatHoldObject, []string{})
+	if err != nil {
+		return err
+	}
+	defer holdsIterator.Close()
+
+	for holdsIterator.HasNext() {
+		responseRange, err := holdsIterator.Next()
+		if err != nil {
+			return err
+		}
+		var hold SeatHold
+		err = json.Unmarshal(responseRange.Value, &hold)
+		if err != nil {
+			return err
+		}
+		if hold.HoldStatus == "Confirmed" && hold.BookingId != "" && hold.ShowId != "" {
+			heldShowIds[hold.BookingId] = hold.ShowId
+		}
+	}
+
+	bookingsIterator, err := stub.GetStateByPartialCompositeKey(domain.UserBookingIndex, []string{})
+	if err != nil {
+		return err
+	}
+	defer bookingsIterator.Close()
+
+	for bookingsIterator.HasNext() {
+		responseRange, err := bookingsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return err
+		}
+		bookingAsBytes, err := stub.GetState(compositeKeyParts[1])
+		if err != nil {
+			return err
+		} else if bookingAsBytes == nil {
+			continue
+		}
+		booking, err := domain.UnmarshalBooking(bookingAsBytes)
+		if err != nil {
+			return err
+		}
+
+		showId, confirmedFromHold := heldShowIds[booking.BookingId]
+		if booking.ShowId != "" || !confirmedFromHold {
+			err = putShowBookingIndex(stub, booking)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		staleIndexKey, err := showBookingKey(stub, booking)
+		if err != nil {
+			return err
+		}
+		err = stub.DelState(staleIndexKey)
+		if err != nil {
+			return err
+		}
+		booking.ShowId = showId
+		err = putBooking(stub, booking)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBookingById - Booking Details for the requested Booking ID
+func (t *BookingChaincode) getBookingById(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	var bookingId string
+	var err error
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Booking ID to fetch the details")
+	}
+	bookingId = args[0]
+
+	valAsbytes, err := stub.GetState(bookingId)
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for given Booking ID " + bookingId)
+	} else if valAsbytes == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Booking found for the requested Booking ID: " + bookingId)
+	}
+
+	var booking domain.Booking
+	err = json.Unmarshal(valAsbytes, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = checkOwner(stub, booking.OwnerId)
+	if err != nil {
+		return unauthorized("getBookingById", err)
+	}
+
+	return shim.Success(valAsbytes)
+}
+
+// getBookingHistory - Every version of a Booking with the ID and time of the transaction that wrote it, for support
+// to reconstruct disputes. Allowed to the Owner of the latest version and to box-office staff.
+func (t *BookingChaincode) getBookingHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Booking ID to fetch the history")
+	}
+	bookingId := args[0]
+
+	versions, err := domain.BookingHistory(stub, bookingId)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	ownerId := ""
+	for _, version := range versions {
+		if version.Booking != nil {
+			ownerId = version.Booking.OwnerId
+		}
+	}
+	if ownerId == "" {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Booking found for the requested Booking ID: " + bookingId)
+	}
+	err = checkOwner(stub, ownerId)
+	if err != nil {
+		return unauthorized("getBookingHistory", err)
+	}
+
+	versionsAsBytes, err := json.Marshal(versions)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	return shim.Success(versionsAsBytes)
+}
+
+// getBookingsByUser - All the Bookings of an Owner ID (MSP ID/enrollment ID), the caller's own by default,
+// walking the indexUserBooking index
+func (t *BookingChaincode) getBookingsByUser(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) > 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting optionally the Owner ID to fetch the bookings")
+	}
+
+	// Customers get their own bookings, box-office staff can ask for anyone's
+	currCaller, err := getCaller(stub)
+	if err != nil {
+		return unauthorized("getBookingsByUser", err)
+	}
+	ownerId := currCaller.OwnerId
+	if len(args) == 1 && args[0] != "" {
+		ownerId = args[0]
+	}
+	err = checkOwner(stub, ownerId)
+	if err != nil {
+		return unauthorized("getBookingsByUser", err)
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(domain.UserBookingIndex, []string{ownerId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+	defer resultsIterator.Close()
+
+	bookingsList := []domain.Booking{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		bookingId := compositeKeyParts[1]
+
+		bookingAsBytes, err := stub.GetState(bookingId)
+		if err != nil {
+			return domain.Failed(err)
+		} else if bookingAsBytes == nil {
+			continue
+		}
+
+		var booking domain.Booking
+		err = json.Unmarshal(bookingAsBytes, &booking)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		bookingsList = append(bookingsList, booking)
+	}
+
+	bookingsListAsBytes, err := json.Marshal(bookingsList)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	return shim.Success(bookingsListAsBytes)
+}
+
+// queryBookings - Bookings matching a Mango selector, a page at a time, through a CouchDB rich query. Args are the
+// selector, the page size and the bookmark of the previous page. The selector can only filter the fields of
+// bookingQueryFields. Customers only get their own bookings, box-office staff get everyone's.
+func (t *BookingChaincode) queryBookings(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Selector, Page size and Bookmark")
+	}
+	selector, err := domain.ParseSelector(args[0], bookingQueryFields)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	pageSize, err := strconv.Atoi(args[1])
+	if err != nil || pageSize <= 0 || pageSize > maxBookingPageSize {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Page size between 1 and "+strconv.Itoa(maxBookingPageSize))
+	}
+	bookmark := args[2]
+
+	currCaller, err := getCaller(stub)
+	if err != nil {
+		return unauthorized("queryBookings", err)
+	}
+	if !currCaller.isBoxOffice() {
+		ownerId, found := selector["ownerId"]
+		if found && ownerId != currCaller.OwnerId {
+			return unauthorized("queryBookings", fmt.Errorf("%s can only query its own bookings", currCaller.OwnerId))
+		}
+		selector["ownerId"] = currCaller.OwnerId
+	}
+
+	query, err := selector.Query(domain.BookingDocType)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(query, int32(pageSize), bookmark)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	defer resultsIterator.Close()
+
+	bookingsList := []domain.Booking{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		booking, err := domain.UnmarshalBooking(queryResult.Value)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		bookingsList = append(bookingsList, *booking)
+	}
+
+	bookingPage := BookingPage{
+		Bookings:            bookingsList,
+		Bookmark:            responseMetadata.Bookmark,
+		FetchedRecordsCount: int(responseMetadata.FetchedRecordsCount)}
+	bookingPageAsBytes, err := json.Marshal(bookingPage)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	return shim.Success(bookingPageAsBytes)
+}
+
+// holdSeats - Holds seats of a show for a User for holdDuration, the seats can be booked with confirmHold until then.
+// Args are User, Show ID, Number of Tickets and optionally the Seat Numbers. The Hold ID is the Transaction ID.
+func (t *BookingChaincode) holdSeats(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - holdSeats ###########")
+
+	if len(args) < 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting User, Show ID, Number of Tickets and optionally Seat Numbers")
+	}
+	heldBy, err := customerFor(stub, args[0])
+	if err != nil {
+		return unauthorized("holdSeats", err)
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[1])
+	if err != nil {
+		return domain.Failed(err)
+	}
+	if _, err := strconv.Atoi(args[2]); err != nil {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting integer value for Number of Tickets")
+	}
+
+	holdId := stub.GetTxID()
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	hold, err := createHold(stub, holdId, heldBy, movieId, timeSlot, args[2], args[3:], currTime.Add(holdDuration))
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	holdAsBytes, err := json.Marshal(hold)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = domain.SetEvent(stub, &domain.Event{Message: "Seats held succcessfully", HoldId: holdId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	logger.Info("Seats held. Hold ID: ", holdId)
+	return shim.Success(holdAsBytes)
+}
+
+// confirmHold - Books the seats of an open Hold at the prices they were held at. The Booking ID is the Transaction ID.
+func (t *BookingChaincode) confirmHold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - confirmHold ###########")
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Hold ID to confirm")
+	}
+	holdId := args[0]
+
+	hold, err := getOpenHold(stub, holdId)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = checkOwner(stub, hold.OwnerId)
+	if err != nil {
+		return unauthorized("confirmHold", err)
+	}
+
+	bookingId := stub.GetTxID()
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	expiryTime, err := time.Parse(time.RFC3339Nano, hold.ExpiryTime)
+	if err != nil {
+		return domain.Failed(err)
+	} else if !currTime.Before(expiryTime) {
+		return domain.ErrorResponse(domain.CodeConflict, "Hold " + holdId + " expired at " + hold.ExpiryTime)
+	}
+
+	// ---- CALLING MOVIES CHAINCODE TO BOOK THE HELD SEATS ---- //
+	movieId, timeSlot := showKeyParts(hold.ShowId, hold.MovieName, hold.TimeSlot)
+	confirmArgs := []string{"confirmHeldSeats", movieId, timeSlot, holdId, bookingId}
+	for _, heldSeat := range hold.Seats {
+		confirmArgs = append(confirmArgs, heldSeat.SeatNumber)
+	}
+	response := invokeMovies(stub, util.ToChaincodeArgs(confirmArgs...))
+	if response.Status != shim.OK {
+		return domain.Failed(moviesError(response))
+	}
+
+	hold.BookingId = bookingId
+	err = closeHold(stub, hold, "Confirmed")
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	heldQuote := domain.ShowQuote{
+		ShowId:     hold.ShowId,
+		MovieName:  hold.MovieName,
+		TimeSlot:   hold.TimeSlot,
+		TheaterId:  hold.TheaterId,
+		Currency:   hold.Currency,
+		Seats:      hold.Seats,
+		TotalPrice: hold.TotalPrice}
+
+	return writeBooking(stub, &customer{OwnerId: hold.OwnerId, Name: hold.HeldByUser}, bookingId, currTime, &heldQuote)
+}
+
+// releaseHold - Gives the seats of an open Hold back to the show
+func (t *BookingChaincode) releaseHold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - releaseHold ###########")
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Hold ID to release")
+	}
+	holdId := args[0]
+
+	hold, err := getOpenHold(stub, holdId)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = checkOwner(stub, hold.OwnerId)
+	if err != nil {
+		return unauthorized("releaseHold", err)
+	}
+
+	err = releaseHeldSeats(stub, hold)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = closeHold(stub, hold, "Released")
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	// The freed seats are offered to the waitlist by promoteWaitlist for the Show ID of the event
+	movieId, timeSlot := showKeyParts(hold.ShowId, hold.MovieName, hold.TimeSlot)
+	err = domain.SetEvent(stub, &domain.Event{Message: "Held seats released succcessfully", HoldId: holdId, ShowId: domain.ShowKey(movieId, timeSlot)})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Hold released successfully. Hold ID: " + holdId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
+}
+
+// sweepExpiredHolds - Releases the seats of every Hold that has expired and marks the Holds Expired. Expired seats can
+// already be booked before the sweep, the sweep only tidies the seat inventory and the Holds. Returns the swept Hold IDs.
+func (t *BookingChaincode) sweepExpiredHolds(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - sweepExpiredHolds ###########")
+
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	sweepUntil := currTime.Format(sortableTimeFormat)
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(holdExpiryIndex, []string{})
+	if err != nil {
+		return domain.Failed(err)
+	}
+	defer resultsIterator.Close()
+
+	// The index is in expiry order, so the walk stops at the first Hold that is still running
+	expiredHoldIds := []string{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		if compositeKeyParts[0] > sweepUntil {
+			break
+		}
+		expiredHoldIds = append(expiredHoldIds, compositeKeyParts[1])
+	}
+
+	// Offering the freed seats to the waitlists once every expired Hold is released, in the order the shows come up
+	releasedShows := [][]string{}
+	for _, holdId := range expiredHoldIds {
+		hold, err := getOpenHold(stub, holdId)
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		err = releaseHeldSeats(stub, hold)
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		err = closeHold(stub, hold, "Expired")
+		if err != nil {
+			return domain.Failed(err)
+		}
+		movieId, timeSlot := showKeyParts(hold.ShowId, hold.MovieName, hold.TimeSlot)
+		releasedShows = append(releasedShows, []string{movieId, timeSlot})
+	}
+
+	promotions := []domain.WaitlistPromotion{}
+	promotedShows := map[string]bool{}
+	for _, releasedShow := range releasedShows {
+		if promotedShows[releasedShow[0]+"_"+releasedShow[1]] {
+			continue
+		}
+		promotedShows[releasedShow[0]+"_"+releasedShow[1]] = true
+
+		showPromotions, err := promoteShowWaitlist(stub, releasedShow[0], releasedShow[1])
+		if err != nil {
+			return domain.Failed(err)
+		}
+		promotions = append(promotions, showPromotions...)
+	}
+
+	if len(promotions) > 0 {
+		err = setWaitlistPromotedEvent(stub, "Expired holds swept", promotions)
+		if err != nil {
+			return domain.Failed(err)
+		}
+	}
+
+	expiredHoldIdsAsBytes, err := json.Marshal(expiredHoldIds)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	logger.Info("Expired holds swept: ", len(expiredHoldIds))
+	return shim.Success(expiredHoldIdsAsBytes)
+}
+
+// createHold - Holds seats of a show through the Movies chaincode and writes the Hold, without an event.
+// reqNmbrOfTickets is passed on as given and requestedSeats may be empty to take the first free seats.
+func createHold(stub shim.ChaincodeStubInterface, holdId string, heldBy *customer, movieId string, timeSlot string, reqNmbrOfTickets string, requestedSeats []string, expiryTime time.Time) (*SeatHold, error) {
+
+	currTime, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// ---- CALLING MOVIES CHAINCODE TO HOLD THE SEATS ---- //
+	holdArgs := append([]string{"holdShowSeats", movieId, timeSlot, holdId, expiryTime.Format(time.RFC3339Nano), reqNmbrOfTickets}, requestedSeats...)
+	response := invokeMovies(stub, util.ToChaincodeArgs(holdArgs...))
+	if response.Status != shim.OK {
+		return nil, moviesError(response)
+	}
+
+	var heldQuote domain.ShowQuote
+	err = json.Unmarshal(response.Payload, &heldQuote)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := &SeatHold{
+		HoldId:     holdId,
+		HeldByUser: heldBy.Name,
+		OwnerId:    heldBy.OwnerId,
+		ShowId:     heldQuote.ShowId,
+		MovieName:  heldQuote.MovieName,
+		TimeSlot:   heldQuote.TimeSlot,
+		TheaterId:  heldQuote.TheaterId,
+		Seats:      heldQuote.Seats,
+		TotalPrice: heldQuote.TotalPrice,
+		Currency:   heldQuote.Currency,
+		HoldTime:   currTime.Format(time.RFC3339Nano),
+		ExpiryTime: expiryTime.Format(time.RFC3339Nano),
+		HoldStatus: "Held"}
+
+	err = putHold(stub, hold)
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// getHold - Hold Details for the requested Hold ID
+func (t *BookingChaincode) getHold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Hold ID to fetch the details")
+	}
+	holdId := args[0]
+
+	hold, err := getSeatHold(stub, holdId)
+	if err != nil {
+		return domain.Failed(err)
+	} else if hold == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Hold found for the requested Hold ID: " + holdId)
+	}
+	err = checkOwner(stub, hold.OwnerId)
+	if err != nil {
+		return unauthorized("getHold", err)
+	}
+
+	holdAsBytes, err := json.Marshal(hold)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	return shim.Success(holdAsBytes)
+}
+
+// getSeatHold - Reads a Hold, returns nil when there is no Hold for the Hold ID
+func getSeatHold(stub shim.ChaincodeStubInterface, holdId string) (*SeatHold, error) {
+
+	holdKey, err := stub.CreateCompositeKey(seatHoldObject, []string{holdId})
+	if err != nil {
+		return nil, err
+	}
+
+	holdAsBytes, err := stub.GetState(holdKey)
+	if err != nil || holdAsBytes == nil {
+		return nil, err
+	}
+
+	var hold SeatHold
+	err = json.Unmarshal(holdAsBytes, &hold)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// getOpenHold - Reads a Hold that is still Held, errors when it does not exist or has been closed
+func getOpenHold(stub shim.ChaincodeStubInterface, holdId string) (*SeatHold, error) {
+
+	hold, err := getSeatHold(stub, holdId)
+	if err != nil {
+		return nil, err
+	} else if hold == nil {
+		return nil, domain.NewError(domain.CodeNotFound, "No Hold found for the requested Hold ID: %s", holdId)
+	} else if hold.HoldStatus != "Held" {
+		return nil, domain.NewError(domain.CodeConflict, "Hold %s is already %s", holdId, strings.ToLower(hold.HoldStatus))
+	}
+	return hold, nil
+}
+
+// putHold - Writes a Hold under its Hold ID and keeps it in the expiry index while it is Held
+func putHold(stub shim.ChaincodeStubInterface, hold *SeatHold) error {
+
+	holdAsBytes, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+
+	holdKey, err := stub.CreateCompositeKey(seatHoldObject, []string{hold.HoldId})
+	if err != nil {
+		return err
+	}
+
+	err = stub.PutState(holdKey, holdAsBytes)
+	if err != nil {
+		return err
+	}
+
+	expiryIndexKey, err := holdExpiryKey(stub, hold)
+	if err != nil {
+		return err
+	}
+
+	if hold.HoldStatus != "Held" {
+		return stub.DelState(expiryIndexKey)
+	}
+	value := []byte{0x00}
+	return stub.PutState(expiryIndexKey, value)
+}
+
+// closeHold - Moves a Hold out of Held into the given status, taking it off the expiry index
+func closeHold(stub shim.ChaincodeStubInterface, hold *SeatHold, holdStatus string) error {
+
+	hold.HoldStatus = holdStatus
+	return putHold(stub, hold)
+}
+
+// holdExpiryKey - Key of a Hold in the expiry index
+func holdExpiryKey(stub shim.ChaincodeStubInterface, hold *SeatHold) (string, error) {
+
+	expiryTime, err := time.Parse(time.RFC3339Nano, hold.ExpiryTime)
+	if err != nil {
+		return "", err
+	}
+	return stub.CreateCompositeKey(holdExpiryIndex, []string{expiryTime.UTC().Format(sortableTimeFormat), hold.HoldId})
+}
+
+// releaseHeldSeats - Gives the seats still held for a Hold back to the show through the Movies chaincode
+func releaseHeldSeats(stub shim.ChaincodeStubInterface, hold *SeatHold) error {
+
+	movieId, timeSlot := showKeyParts(hold.ShowId, hold.MovieName, hold.TimeSlot)
+	releaseArgs := []string{"releaseHeldSeats", movieId, timeSlot, hold.HoldId}
+	for _, heldSeat := range hold.Seats {
+		releaseArgs = append(releaseArgs, heldSeat.SeatNumber)
+	}
+	response := invokeMovies(stub, util.ToChaincodeArgs(releaseArgs...))
+	if response.Status != shim.OK {
+		return moviesError(response)
+	}
+	return nil
+}
+
+// joinWaitlist - Queues a User for seats of a show that cannot take the requested Number of Tickets. Args are User,
+// Show ID and Number of Tickets. When seats are freed the entry is promoted to a Hold, see promoteShowWaitlist.
+// The Entry ID is the Transaction ID.
+func (t *BookingChaincode) joinWaitlist(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - joinWaitlist ###########")
+
+	if len(args) != 3 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting User, Show ID and Number of Tickets")
+	}
+	waitingUser, err := customerFor(stub, args[0])
+	if err != nil {
+		return unauthorized("joinWaitlist", err)
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[1])
+	if err != nil {
+		return domain.Failed(err)
+	}
+	reqNmbrOfTickets, err := strconv.Atoi(args[2])
+	if err != nil || reqNmbrOfTickets <= 0 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a positive integer value for Number of Tickets")
+	}
+
+	// ---- CALLING MOVIES CHAINCODE TO CHECK AVAILABILITY ---- //
+	chainCodeArgs := util.ToChaincodeArgs("getMoviesByName", movieId, timeSlot)
+	response := invokeMovies(stub, chainCodeArgs)
+	if response.Status != shim.OK {
+		return domain.Failed(moviesError(response))
+	}
+	m, err := domain.UnmarshalShow(response.Payload)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	if m.ShowStatus == "Cancelled" {
+		return domain.ErrorResponse(domain.CodeConflict, "Show " + m.ShowId + " is cancelled")
+	}
+
+	// Only a show that is full for the request is waited for, the same way a booking would be refused
+	if m.RemainingTickets >= reqNmbrOfTickets {
+		return domain.ErrorResponse(domain.CodeConflict, "Seats are available for " + m.MovieName + " at " + timeSlot + ", book them instead of joining the waitlist")
+	}
+
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	entry := WaitlistEntry{
+		EntryId:          stub.GetTxID(),
+		WaitingUser:      waitingUser.Name,
+		OwnerId:          waitingUser.OwnerId,
+		ShowId:           m.ShowId,
+		MovieName:        m.MovieName,
+		TimeSlot:         m.AvailalbeTimeSlots,
+		ReqNmbrOfTickets: reqNmbrOfTickets,
+		JoinTime:         currTime.Format(time.RFC3339Nano),
+		EntryStatus:      "Waiting"}
+
+	err = putWaitlistEntry(stub, &entry)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	err = domain.SetEvent(stub, &domain.Event{Message: "Joined the waitlist succcessfully", EntryId: entry.EntryId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Joined the waitlist successfully. Entry ID: " + entry.EntryId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
+}
+
+// leaveWaitlist - Takes a Waiting entry off the waitlist of its show
+func (t *BookingChaincode) leaveWaitlist(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - leaveWaitlist ###########")
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Entry ID to leave the waitlist")
+	}
+	entryId := args[0]
+
+	entry, err := getWaitlistEntry(stub, entryId)
+	if err != nil {
+		return domain.Failed(err)
+	} else if entry == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Waitlist entry found for the requested Entry ID: " + entryId)
+	} else if entry.EntryStatus != "Waiting" {
+		return domain.ErrorResponse(domain.CodeConflict, "Waitlist entry " + entryId + " is already " + strings.ToLower(entry.EntryStatus))
+	}
+	err = checkOwner(stub, entry.OwnerId)
+	if err != nil {
+		return unauthorized("leaveWaitlist", err)
+	}
+
+	entry.EntryStatus = "Left"
+	err = putWaitlistEntry(stub, entry)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	msg := "Left the waitlist successfully. Entry ID: " + entryId
+	logger.Info(msg)
+	return shim.Success([]byte(msg))
+}
+
+// getWaitlist - Waiting entries of a show, head of the queue first
+func (t *BookingChaincode) getWaitlist(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID to fetch the waitlist")
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[0])
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	entries, err := waitingEntries(stub, movieId, timeSlot)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	entriesAsBytes, err := json.Marshal(entries)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	return shim.Success(entriesAsBytes)
+}
+
+// promoteWaitlist - Offers the free seats of a show to its waitlist. Args are Show ID. Run it after the transaction that
+// freed the seats has committed, as a transaction does not read its own writes and would still find them taken.
+// Returns the promotions, empty once the head of the waitlist cannot be seated.
+func (t *BookingChaincode) promoteWaitlist(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - promoteWaitlist ###########")
+
+	if len(args) != 1 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID")
+	}
+	movieId, timeSlot, err := domain.ParseShowId(args[0])
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	promotions, err := promoteShowWaitlist(stub, movieId, timeSlot)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	if len(promotions) > 0 {
+		err = setWaitlistPromotedEvent(stub, "Seats freed for show "+args[0], promotions)
+		if err != nil {
+			return domain.Failed(err)
+		}
+	}
+
+	promotionsAsBytes, err := json.Marshal(promotions)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	logger.Info("Waitlist entries promoted: ", len(promotions))
+	return shim.Success(promotionsAsBytes)
+}
+
+// promoteShowWaitlist - Offers the free seats of a show to the head of its waitlist, which gets a Hold of its Number of
+// Tickets for waitlistHoldDuration when the show can seat it. Nobody further down the queue overtakes it. A second Hold
+// in the same transaction would be handed the seats of the first, as the transaction does not read its own writes, so
+// one entry is promoted per transaction. The Hold ID is the Transaction ID with "_0" appended.
+func promoteShowWaitlist(stub shim.ChaincodeStubInterface, movieId string, timeSlot string) ([]domain.WaitlistPromotion, error) {
+
+	promotions := []domain.WaitlistPromotion{}
+
+	entries, err := waitingEntries(stub, movieId, timeSlot)
+	if err != nil || len(entries) == 0 {
+		return promotions, err
+	}
+	entry := &entries[0]
+
+	// ---- CALLING MOVIES CHAINCODE TO CHECK AVAILABILITY ---- //
+	chainCodeArgs := util.ToChaincodeArgs("getMoviesByName", movieId, timeSlot)
+	response := invokeMovies(stub, chainCodeArgs)
+	if response.Status != shim.OK {
+		return nil, moviesError(response)
+	}
+	m, err := domain.UnmarshalShow(response.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if m.RemainingTickets < entry.ReqNmbrOfTickets {
+		logger.Info("Waitlist promotion stopped at Entry ID: ", entry.EntryId, m.RemainingTickets)
+		return promotions, nil
+	}
+
+	currTime, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	expiryTime := currTime.Add(waitlistHoldDuration)
+	holdId := stub.GetTxID() + "_0"
+
+	hold, err := createHold(stub, holdId, &customer{OwnerId: entry.OwnerId, Name: entry.WaitingUser}, movieId, timeSlot, strconv.Itoa(entry.ReqNmbrOfTickets), []string{}, expiryTime)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.EntryStatus = "Promoted"
+	entry.HoldId = holdId
+	err = putWaitlistEntry(stub, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	seatNumbers := []string{}
+	for _, heldSeat := range hold.Seats {
+		seatNumbers = append(seatNumbers, heldSeat.SeatNumber)
+	}
+	promotions = append(promotions, domain.WaitlistPromotion{
+		EntryId:     entry.EntryId,
+		WaitingUser: entry.WaitingUser,
+		OwnerId:     entry.OwnerId,
+		ShowId:      hold.ShowId,
+		MovieName:   entry.MovieName,
+		TimeSlot:    entry.TimeSlot,
+		HoldId:      holdId,
+		SeatNumbers: seatNumbers,
+		ExpiryTime:  hold.ExpiryTime})
+
+	return promotions, nil
+}
+
+// setWaitlistPromotedEvent - Sets the waitlistPromoted event for the promotions of this transaction
+func setWaitlistPromotedEvent(stub shim.ChaincodeStubInterface, reason string, promotions []domain.WaitlistPromotion) error {
+
+	return domain.SetWaitlistPromotedEvent(stub, &domain.WaitlistPromotedEvent{
+		Message:    "Waitlist promoted to held seats",
+		Reason:     reason,
+		Promotions: promotions})
+}
+
+// waitingEntries - Waiting entries of a show in FIFO order, walking the indexShowWaitlist index
+func waitingEntries(stub shim.ChaincodeStubInterface, movieId string, timeSlot string) ([]WaitlistEntry, error) {
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(showWaitlistIndex, []string{movieId, timeSlot})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	entries := []WaitlistEntry{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err := getWaitlistEntry(stub, compositeKeyParts[3])
+		if err != nil {
+			return nil, err
+		} else if entry != nil && entry.EntryStatus == "Waiting" {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries, nil
+}
+
+// getWaitlistEntry - Reads a waitlist entry, returns nil when there is no entry for the Entry ID
+func getWaitlistEntry(stub shim.ChaincodeStubInterface, entryId string) (*WaitlistEntry, error) {
+
+	entryKey, err := stub.CreateCompositeKey(waitlistEntryObject, []string{entryId})
+	if err != nil {
+		return nil, err
+	}
+
+	entryAsBytes, err := stub.GetState(entryKey)
+	if err != nil || entryAsBytes == nil {
+		return nil, err
+	}
+
+	var entry WaitlistEntry
+	err = json.Unmarshal(entryAsBytes, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// putWaitlistEntry - Writes a waitlist entry under its Entry ID and keeps it in the queue of its show while it is Waiting
+func putWaitlistEntry(stub shim.ChaincodeStubInterface, entry *WaitlistEntry) error {
+
+	entryAsBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	entryKey, err := stub.CreateCompositeKey(waitlistEntryObject, []string{entry.EntryId})
+	if err != nil {
+		return err
+	}
+
+	err = stub.PutState(entryKey, entryAsBytes)
+	if err != nil {
+		return err
+	}
+
+	joinTime, err := time.Parse(time.RFC3339Nano, entry.JoinTime)
+	if err != nil {
+		return err
+	}
+	movieId, timeSlot := showKeyParts(entry.ShowId, entry.MovieName, entry.TimeSlot)
+	queueKey, err := stub.CreateCompositeKey(showWaitlistIndex, []string{movieId, timeSlot, joinTime.UTC().Format(sortableTimeFormat), entry.EntryId})
+	if err != nil {
+		return err
+	}
+
+	if entry.EntryStatus != "Waiting" {
+		return stub.DelState(queueKey)
+	}
+	value := []byte{0x00}
+	return stub.PutState(queueKey, value)
+}
+
+// redeemBeverageExchange - Marks the Water to Soda exchange of a Receipt as redeemed at the concession stand.
+// Args are Booking ID and Receipt Number; a Receipt can only be redeemed once, only while its Booking stands and only
+// when the theater's quota of the day covers it.
+func (t *BookingChaincode) redeemBeverageExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - redeemBeverageExchange ###########")
+
+	if len(args) != 2 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Booking ID and Receipt Number")
+	}
+	bookingId := args[0]
+	receiptNumber := args[1]
+
+	// Redemptions are made by the staff at the concession stand
+	err := checkStaff(stub, domain.BoxOfficeRole, domain.TheaterAdminRole)
+	if err != nil {
+		return unauthorized("redeemBeverageExchange", err)
+	}
+
+	bookingAsBytes, err := stub.GetState(bookingId)
+	if err != nil {
+		return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for given Booking ID " + bookingId)
+	} else if bookingAsBytes == nil {
+		return domain.ErrorResponse(domain.CodeNotFound, "No Booking found for the requested Booking ID: " + bookingId)
+	}
+
+	var booking domain.Booking
+	err = json.Unmarshal(bookingAsBytes, &booking)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	if booking.BookingStatus == "Cancelled" || booking.BookingStatus == "RefundDue" {
+		return domain.ErrorResponse(domain.CodeConflict, "Booking " + bookingId + " is cancelled")
+	}
+
+	currTime, err := txTime(stub)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	for i := range booking.SeatDetails {
+		seatDetails := &booking.SeatDetails[i]
+		if seatDetails.ReceiptNumber != receiptNumber {
+			continue
+		}
+
+		if seatDetails.WaterToSodaExchangeFlag != "True" {
+			return domain.ErrorResponse(domain.CodeInvalidArgument, "Receipt " + receiptNumber + " does not have the Water to Soda exchange")
+		} else if seatDetails.BeverageRedeemedFlag == "True" {
+			return domain.ErrorResponse(domain.CodeConflict, "Receipt " + receiptNumber + " was already redeemed at " + seatDetails.RedemptionTime)
+		}
+
+		// The exchanges of the Booking are covered in Receipt order, as far as the quota of the day reaches
+		coveredExchanges, err := bookingExchanges(stub, &booking)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		exchangeIndex := 0
+		for _, otherSeat := range booking.SeatDetails[:i] {
+			if otherSeat.WaterToSodaExchangeFlag == "True" {
+				exchangeIndex = exchangeIndex + 1
+			}
+		}
+		if exchangeIndex >= coveredExchanges {
+			return domain.ErrorResponse(domain.CodeSoldOut, "The Water to Soda exchange quota of the day was used up by earlier bookings, Receipt " + receiptNumber + " is not covered")
+		}
+
+		seatDetails.BeverageRedeemedFlag = "True"
+		seatDetails.RedemptionTime = currTime.Format(time.RFC3339Nano)
+		err = putBooking(stub, &booking)
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		err = domain.SetEvent(stub, &domain.Event{Message: "Water to Soda exchange redeemed succcessfully", ReceiptNumber: receiptNumber})
+		if err != nil {
+			return domain.Failed(err)
+		}
+
+		msg := "Water to Soda exchange redeemed successfully. Receipt Number: " + receiptNumber
+		logger.Info(msg)
+		return shim.Success([]byte(msg))
+	}
+
+	return domain.ErrorResponse(domain.CodeNotFound, "No Receipt " + receiptNumber + " found in Booking " + bookingId)
+}
+
+// getBeverageQuota - Water to Soda exchange quota of a theater. Args are Theater ID and optionally the date
+// (YYYY-MM-DD), today by default.
+func (t *BookingChaincode) getBeverageQuota(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 && len(args) != 2 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Theater ID and optionally the date")
+	}
+	theaterId := args[0]
+
+	var date string
+	if len(args) == 2 {
+		quotaDate, err := time.Parse(quotaDateFormat, args[1])
+		if err != nil {
+			return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting the date as YYYY-MM-DD")
+		}
+		date = quotaDate.Format(quotaDateFormat)
+	} else {
+		currTime, err := txTime(stub)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		date = currTime.Format(quotaDateFormat)
+	}
+
+	beverageQuota, err := getDailyBeverageQuota(stub, theaterId, date)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	beverageQuotaAsBytes, err := json.Marshal(beverageQuota)
+	if err != nil {
+		return domain.Failed(err)
+	}
+	return shim.Success(beverageQuotaAsBytes)
+}
+
+// setBeverageQuota - Configures the daily Water to Soda exchange quota of a theater. Args are Theater ID and the
+// daily quota. The quota is read at every redemption, so the new quota also applies to the bookings made today.
+func (t *BookingChaincode) setBeverageQuota(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	logger.Info("########### START - setBeverageQuota ###########")
+
+	if len(args) != 2 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Theater ID and Daily quota")
+	}
+	theaterId := args[0]
+	dailyQuota, err := strconv.Atoi(args[1])
+	if err != nil || dailyQuota < 0 {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a non negative integer value for Daily quota")
+	}
+	if theaterId == "" {
+		return domain.ErrorResponse(domain.CodeInvalidArgument, "Theater ID must not be empty")
+	}
+
+	err = checkStaff(stub, domain.TheaterAdminRole)
+	if err != nil {
+		return unauthorized("setBeverageQuota", err)
+	}
+
+	configKey, err := stub.CreateCompositeKey(beverageQuotaConfigObject, []string{theaterId})
+	if err != nil {
+		return domain.Failed(err)
+	}
+	configAsBytes, err := json.Marshal(BeverageQuotaConfig{TheaterId: theaterId, DailyQuota: dailyQuota})
+	if err != nil {
+		return domain.Failed(err)
+	}
+	err = stub.PutState(configKey, configAsBytes)
+	if err != nil {
+		return domain.Failed(err)
+	}
+
+	logger.Info("Daily beverage quota saved for ", theaterId, dailyQuota)
+	return shim.Success(nil)
+}
+
+// getDailyBeverageQuota - Quota of a theater for a date, adding up the consumption records of the date
+func getDailyBeverageQuota(stub shim.ChaincodeStubInterface, theaterId string, date string) (*BeverageQuota, error) {
+
+	dailyQuota, err := getDailyQuotaConfig(stub, theaterId)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(beverageConsumptionObject, []string{theaterId, date})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	requested := 0
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var consumption BeverageConsumption
+		err = json.Unmarshal(responseRange.Value, &consumption)
+		if err != nil {
+			return nil, err
+		}
+		requested = requested + consumption.Exchanges
+	}
+
+	consumed := requested
+	if consumed > dailyQuota {
+		consumed = dailyQuota
+	}
+	return &BeverageQuota{TheaterId: theaterId, Date: date, DailyQuota: dailyQuota, Requested: requested, Consumed: consumed, Remaining: dailyQuota - consumed}, nil
+}
+
+// getDailyQuotaConfig - Configured daily quota of a theater, defaultDailyBeverageQuota when it has none
+func getDailyQuotaConfig(stub shim.ChaincodeStubInterface, theaterId string) (int, error) {
+
+	configKey, err := stub.CreateCompositeKey(beverageQuotaConfigObject, []string{theaterId})
+	if err != nil {
+		return 0, err
+	}
+	configAsBytes, err := stub.GetState(configKey)
+	if err != nil {
+		return 0, err
+	}
+
+	config := BeverageQuotaConfig{TheaterId: theaterId, DailyQuota: defaultDailyBeverageQuota}
+	if configAsBytes != nil {
+		err = json.Unmarshal(configAsBytes, &config)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return config.DailyQuota, nil
+}
+
+// bookingExchanges - Number of the Water to Soda exchanges of a Booking the quota of the day covers, the quota going
+// to the bookings in the order they were made. The records are read up to the Booking's own only, so bookings made
+// after it do not conflict with the redemption. Bookings without a record keep the exchanges they were given.
+func bookingExchanges(stub shim.ChaincodeStubInterface, booking *domain.Booking) (int, error) {
+
+	ownConsumption, err := bookingConsumption(booking)
+	if err != nil {
+		// Bookings made before the booking time was recorded have no consumption record
+		return len(booking.SeatDetails), nil
+	}
+	ownKey, err := beverageConsumptionKey(stub, ownConsumption)
+	if err != nil {
+		return 0, err
+	}
+	dailyQuota, err := getDailyQuotaConfig(stub, ownConsumption.TheaterId)
+	if err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(beverageConsumptionObject, []string{ownConsumption.TheaterId, ownConsumption.Date})
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	requestedBefore := 0
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		if responseRange.Key > ownKey {
+			break
+		}
+
+		var consumption BeverageConsumption
+		err = json.Unmarshal(responseRange.Value, &consumption)
+		if err != nil {
+			return 0, err
+		}
+		if responseRange.Key < ownKey {
+			requestedBefore = requestedBefore + consumption.Exchanges
+			continue
+		}
+
+		covered := dailyQuota - requestedBefore
+		if covered < 0 {
+			covered = 0
+		} else if covered > consumption.Exchanges {
+			covered = consumption.Exchanges
+		}
+		return covered, nil
+	}
+
+	return ownConsumption.Exchanges, nil
+}
+
+// returnBeverageQuota - Gives the Water to Soda exchanges of a cancelled Booking that were not redeemed back to the quota
+// of its date, for the bookings made after it
+func returnBeverageQuota(stub shim.ChaincodeStubInterface, booking *domain.Booking) error {
+
+	consumption, err := bookingConsumption(booking)
+	if err != nil {
+		// Bookings made before the booking time was recorded have no consumption record
+		return nil
+	}
+
+	redeemed := 0
+	for _, seatDetails := range booking.SeatDetails {
+		if seatDetails.BeverageRedeemedFlag == "True" {
+			redeemed = redeemed + 1
+		}
+	}
+	if redeemed > 0 {
+		consumption.Exchanges = redeemed
+		return putBeverageConsumption(stub, consumption)
+	}
+
+	consumptionKey, err := beverageConsumptionKey(stub, consumption)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(consumptionKey)
+}
+
+// bookingConsumption - Consumption record of a Booking, asking for the exchange of every seat with the Water to Soda
+// exchange flag on the date of the booking
+func bookingConsumption(booking *domain.Booking) (*BeverageConsumption, error) {
+
+	bookingTime, err := time.Parse(time.RFC3339Nano, booking.BookingTime)
+	if err != nil {
+		return nil, err
+	}
+	theaterId := booking.TheaterId
+	if theaterId == "" {
+		theaterId = defaultTheaterId
+	}
+
+	exchanges := 0
+	for _, seatDetails := range booking.SeatDetails {
+		if seatDetails.WaterToSodaExchangeFlag == "True" {
+			exchanges = exchanges + 1
+		}
+	}
+	return &BeverageConsumption{
+		TheaterId:   theaterId,
+		Date:        bookingTime.UTC().Format(quotaDateFormat),
+		BookingTime: bookingTime.UTC().Format(sortableTimeFormat),
+		BookingId:   booking.BookingId,
+		Exchanges:   exchanges}, nil
+}
+
+// beverageConsumptionKey - Key of a consumption record, in booking order within the theater and date
+func beverageConsumptionKey(stub shim.ChaincodeStubInterface, consumption *BeverageConsumption) (string, error) {
+	return stub.CreateCompositeKey(beverageConsumptionObject, []string{consumption.TheaterId, consumption.Date, consumption.BookingTime, consumption.BookingId})
+}
+
+// putBeverageConsumption - Writes the consumption record of a Booking
+func putBeverageConsumption(stub shim.ChaincodeStubInterface, consumption *BeverageConsumption) error {
+
+	consumptionKey, err := beverageConsumptionKey(stub, consumption)
+	if err != nil {
+		return err
+	}
+
+	consumptionAsBytes, err := json.Marshal(consumption)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(consumptionKey, consumptionAsBytes)
+}
@@ -0,0 +1,817 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chaincode/domain"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Customers booking shows in the tests, the box-office staff and a theater admin of the theater admin MSP
+var (
+	jim          = newTestCaller("Org2MSP", "jim", map[string]string{"hf.EnrollmentID": "Jim"})
+	pam          = newTestCaller("Org2MSP", "pam", map[string]string{"hf.EnrollmentID": "Pam"})
+	boxOffice    = newTestCaller("Org2MSP", "dwight", map[string]string{"hf.EnrollmentID": "Dwight", "role": "boxOffice"})
+	theaterAdmin = newTestCaller("Org1MSP", "admin", map[string]string{"hf.EnrollmentID": "admin"})
+)
+
+// Start time of the show of The Grudge the bookings are made against, and its Show ID
+const morning = "2030-01-02T09:00:00Z"
+
+var grudgeShow = domain.ShowKey("The Grudge", morning)
+
+// testMovies - Stand-in of the Movies chaincode holding the shows the bookings are made against, keyed by movie name
+// and time slot as cc_movies stores them. Seats are numbered 1 to TotalTickets, every seat is a Standard seat at 15000
+// and the taken ones are stored under the show key and Seat Number as "Booked <Booking ID>" or
+// "Held <Hold ID> <Held until>".
+type testMovies struct {
+}
+
+func (m *testMovies) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (m *testMovies) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "getMoviesByName" {
+		showAsBytes, _ := stub.GetState(args[0] + "_" + args[1])
+		if showAsBytes == nil {
+			return shim.Error("No Movie show is running for the requested time slot: " + args[1])
+		}
+		return m.reportShow(stub, m.getShow(stub, args[0], args[1]))
+	} else if function == "initMovieDetails" {
+		total, _ := strconv.Atoi(args[2])
+		remaining, _ := strconv.Atoi(args[3])
+		return m.putShow(stub, domain.Show{ShowId: domain.ShowKey(args[0], args[1]), MovieName: args[0], AvailalbeTimeSlots: args[1], TotalTickets: total, RemainingTickets: remaining})
+	} else if function == "reserveShowSeats" {
+		return m.takeSeats(stub, args[0], args[1], "Booked "+args[2], args[3], args[4:])
+	} else if function == "holdShowSeats" {
+		return m.takeSeats(stub, args[0], args[1], "Held "+args[2]+" "+args[3], args[4], args[5:])
+	} else if function == "confirmHeldSeats" {
+		return m.confirmHeldSeats(stub, args)
+	} else if function == "releaseShowSeats" {
+		return m.freeSeats(stub, args[0], args[1], "Booked "+args[2]+" ", args[3:])
+	} else if function == "releaseHeldSeats" {
+		return m.freeSeats(stub, args[0], args[1], "Held "+args[2]+" ", args[3:])
+	} else if function == "cancelShow" {
+		return stub.InvokeChaincode("cc_bookings", util.ToChaincodeArgs("markShowRefundDue", args[0], args[1]), "")
+	}
+	return shim.Error("Received unknown function invocation")
+}
+
+func (m *testMovies) getShow(stub shim.ChaincodeStubInterface, movieName string, timeSlot string) domain.Show {
+	var show domain.Show
+	showAsBytes, _ := stub.GetState(movieName + "_" + timeSlot)
+	json.Unmarshal(showAsBytes, &show)
+	return show
+}
+
+func (m *testMovies) putShow(stub shim.ChaincodeStubInterface, show domain.Show) pb.Response {
+	show.HouseFullFlag = "False"
+	if show.RemainingTickets <= 0 {
+		show.HouseFullFlag = "True"
+	}
+	showAsBytes, _ := json.Marshal(show)
+	stub.PutState(show.MovieName+"_"+show.AvailalbeTimeSlots, showAsBytes)
+	return shim.Success(nil)
+}
+
+// reportShow - The show as cc_movies reports it, with the seats held until after the transaction taken off the
+// Remaining Tickets
+func (m *testMovies) reportShow(stub shim.ChaincodeStubInterface, show domain.Show) pb.Response {
+	for i := 1; i <= show.TotalTickets; i++ {
+		seatAsBytes, _ := stub.GetState(show.MovieName + "_" + show.AvailalbeTimeSlots + "_" + strconv.Itoa(i))
+		if strings.HasPrefix(string(seatAsBytes), "Held ") && !m.seatIsFree(stub, show, strconv.Itoa(i)) {
+			show.RemainingTickets = show.RemainingTickets - 1
+		}
+	}
+	show.HouseFullFlag = "False"
+	if show.RemainingTickets <= 0 {
+		show.HouseFullFlag = "True"
+	}
+	showAsBytes, _ := json.Marshal(show)
+	return shim.Success(showAsBytes)
+}
+
+// seatIsFree - Whether a seat of the show exists and is neither booked nor held until after the transaction
+func (m *testMovies) seatIsFree(stub shim.ChaincodeStubInterface, show domain.Show, seatNumber string) bool {
+	number, err := strconv.Atoi(seatNumber)
+	if err != nil || number < 1 || number > show.TotalTickets {
+		return false
+	}
+	seatAsBytes, _ := stub.GetState(show.MovieName + "_" + show.AvailalbeTimeSlots + "_" + seatNumber)
+	seat := strings.Split(string(seatAsBytes), " ")
+	if seat[0] != "Held" {
+		return seatAsBytes == nil
+	}
+	heldUntil, _ := time.Parse(time.RFC3339Nano, seat[2])
+	currTime, _ := txTime(stub)
+	return !currTime.Before(heldUntil)
+}
+
+// takeSeats - Marks the requested seats, or the first free ones, failing when a seat is taken or missing. Booked seats
+// come off the Remaining Tickets.
+func (m *testMovies) takeSeats(stub shim.ChaincodeStubInterface, movieName string, timeSlot string, taken string, reqNmbrOfTickets string, seatNumbers []string) pb.Response {
+	show := m.getShow(stub, movieName, timeSlot)
+	n, _ := strconv.Atoi(reqNmbrOfTickets)
+	for i := 1; len(seatNumbers) < n && i <= show.TotalTickets; i++ {
+		if m.seatIsFree(stub, show, strconv.Itoa(i)) {
+			seatNumbers = append(seatNumbers, strconv.Itoa(i))
+		}
+	}
+	if len(seatNumbers) != n {
+		return shim.Error("Only " + strconv.Itoa(len(seatNumbers)) + " seats are available")
+	}
+
+	quote := domain.ShowQuote{ShowId: show.ShowId, MovieName: movieName, TimeSlot: timeSlot, Currency: "INR"}
+	for _, seatNumber := range seatNumbers {
+		if !m.seatIsFree(stub, show, seatNumber) {
+			return shim.Error("Seat " + seatNumber + " is not free")
+		}
+		stub.PutState(movieName+"_"+timeSlot+"_"+seatNumber, []byte(taken))
+		quote.Seats = append(quote.Seats, domain.SeatQuote{SeatNumber: seatNumber, Category: "Standard", Price: 15000})
+		quote.TotalPrice = quote.TotalPrice + 15000
+	}
+	if strings.HasPrefix(taken, "Booked") {
+		show.RemainingTickets = show.RemainingTickets - n
+		m.putShow(stub, show)
+	}
+
+	quoteAsBytes, _ := json.Marshal(quote)
+	return shim.Success(quoteAsBytes)
+}
+
+// confirmHeldSeats - Books the seats held for the Hold ID, failing when one of them is no longer held
+func (m *testMovies) confirmHeldSeats(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	show := m.getShow(stub, args[0], args[1])
+	quote := domain.ShowQuote{ShowId: show.ShowId, MovieName: args[0], TimeSlot: args[1], Currency: "INR"}
+	for _, seatNumber := range args[4:] {
+		seatAsBytes, _ := stub.GetState(args[0] + "_" + args[1] + "_" + seatNumber)
+		if !strings.HasPrefix(string(seatAsBytes), "Held "+args[2]+" ") || m.seatIsFree(stub, show, seatNumber) {
+			return shim.Error("Seat " + seatNumber + " is no longer held for Hold ID " + args[2])
+		}
+		stub.PutState(args[0]+"_"+args[1]+"_"+seatNumber, []byte("Booked "+args[3]))
+		quote.Seats = append(quote.Seats, domain.SeatQuote{SeatNumber: seatNumber, Category: "Standard", Price: 15000})
+		quote.TotalPrice = quote.TotalPrice + 15000
+	}
+	show.RemainingTickets = show.RemainingTickets - len(quote.Seats)
+	m.putShow(stub, show)
+
+	quoteAsBytes, _ := json.Marshal(quote)
+	return shim.Success(quoteAsBytes)
+}
+
+// freeSeats - Frees the seats taken as given, booked seats go back to the Remaining Tickets
+func (m *testMovies) freeSeats(stub shim.ChaincodeStubInterface, movieName string, timeSlot string, taken string, seatNumbers []string) pb.Response {
+	show := m.getShow(stub, movieName, timeSlot)
+	for _, seatNumber := range seatNumbers {
+		seatAsBytes, _ := stub.GetState(movieName + "_" + timeSlot + "_" + seatNumber)
+		if seatAsBytes == nil || !strings.HasPrefix(string(seatAsBytes)+" ", taken) {
+			continue
+		}
+		stub.DelState(movieName + "_" + timeSlot + "_" + seatNumber)
+		if strings.HasPrefix(taken, "Booked") {
+			show.RemainingTickets = show.RemainingTickets + 1
+		}
+	}
+	return m.putShow(stub, show)
+}
+
+// deployBookings - Deploys the Bookings chaincode next to a Movies stand-in running one show of The Grudge
+func deployBookings(t *testing.T) (*testStub, *testStub) {
+	network := newTestNetwork(t)
+	movies := network.deploy("cc_movies", new(testMovies), jim)
+	movies.mustInvoke(jim, "initMovieDetails", "The Grudge", morning, "100", "100", "False")
+	bookings := network.deploy("cc_bookings", new(BookingChaincode), theaterAdmin, "cc_movies", "mychannel", "Org1MSP")
+	return movies, bookings
+}
+
+// bookingIdOf - Booking ID of the message of a successful booking
+func bookingIdOf(t *testing.T, payload []byte) string {
+	t.Helper()
+	msg := string(payload)
+	if !strings.HasPrefix(msg, "Show booked successfully. Booking ID: ") {
+		t.Fatalf("Expected the show to be booked, got %q", msg)
+	}
+	return msg[strings.LastIndex(msg, " ")+1:]
+}
+
+func TestBookingsByUser(t *testing.T) {
+	movies, bookings := deployBookings(t)
+
+	jimsBooking := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	pamsBooking := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "3"))
+
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", jimsBooking), &booking)
+	if booking.BookedByUser != "Jim" || booking.ReqNmbrOfTickets != 2 || len(booking.SeatDetails) != 2 {
+		t.Errorf("Expected the booking of 2 tickets by Jim, got %+v", booking)
+	}
+	bookings.mustFail(jim, "getBookingById", "Jim_0")
+
+	var bookingsList []domain.Booking
+	unmarshal(t, bookings.mustInvoke(pam, "getBookingsByUser"), &bookingsList)
+	if len(bookingsList) != 1 || bookingsList[0].BookingId != pamsBooking {
+		t.Errorf("Expected booking %s of Pam only, got %+v", pamsBooking, bookingsList)
+	}
+	unmarshal(t, bookings.mustInvoke(boxOffice, "getBookingsByUser"), &bookingsList)
+	if len(bookingsList) != 0 {
+		t.Errorf("Expected no bookings of Dwight, got %+v", bookingsList)
+	}
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(jim, "getMoviesByName", "The Grudge", morning), &show)
+	if show.RemainingTickets != 95 {
+		t.Errorf("The show has %d tickets left after 5 were booked, expected 95", show.RemainingTickets)
+	}
+}
+
+// beverageQuota - Water to Soda exchanges left today at the theater of the stand-in shows
+func beverageQuota(t *testing.T, bookings *testStub) int {
+	t.Helper()
+	var quota BeverageQuota
+	unmarshal(t, bookings.mustInvoke(jim, "getBeverageQuota", defaultTheaterId), &quota)
+	return quota.Remaining
+}
+
+func TestCancelBookingReleasesSeats(t *testing.T) {
+	movies, bookings := deployBookings(t)
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	if quota := beverageQuota(t, bookings); quota != 198 {
+		t.Errorf("%d exchanges left after 2 tickets were booked, expected 198", quota)
+	}
+
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", bookingId), &booking)
+	if booking.BookingStatus != "Cancelled" {
+		t.Errorf("Booking is %s after it was cancelled", booking.BookingStatus)
+	}
+	for _, seat := range booking.SeatDetails {
+		if seat.WaterToSodaExchangeFlag != "False" {
+			t.Errorf("Seat %s of a cancelled booking can still be exchanged", seat.SeatNumber)
+		}
+	}
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(jim, "getMoviesByName", "The Grudge", morning), &show)
+	if show.RemainingTickets != 100 {
+		t.Errorf("The show has %d tickets left after the booking was cancelled, expected 100", show.RemainingTickets)
+	}
+	if quota := beverageQuota(t, bookings); quota != 200 {
+		t.Errorf("%d exchanges left after the booking was cancelled, expected 200", quota)
+	}
+
+	// A second cancellation must not hand the seats back twice
+	bookings.mustFail(jim, "cancelBooking", bookingId)
+	bookings.mustFail(jim, "cancelBooking", "Jim_0")
+	unmarshal(t, movies.mustInvoke(jim, "getMoviesByName", "The Grudge", morning), &show)
+	if show.RemainingTickets != 100 {
+		t.Errorf("The show has %d tickets left after a second cancellation, expected 100", show.RemainingTickets)
+	}
+}
+
+func TestBookingIdsFromTransaction(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	// Both bookings are stamped within the same second of wall-clock time, their IDs must not collide
+	first := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "1"))
+	firstTx := bookings.network.lastTx
+	second := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	secondTx := bookings.network.lastTx
+	if first != firstTx.id || second != secondTx.id {
+		t.Errorf("Expected the Booking IDs to be the IDs of their transactions %s and %s, got %s and %s", firstTx.id, secondTx.id, first, second)
+	}
+
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", second), &booking)
+	bookingTime := secondTx.time.Format(time.RFC3339Nano)
+	if booking.BookingTime != bookingTime {
+		t.Errorf("Booking time is %s, expected the transaction time %s", booking.BookingTime, bookingTime)
+	}
+	if booking.SeatDetails[1].ReceiptNumber != second+"_1" {
+		t.Errorf("Receipt of the second seat is %s, expected %s_1", booking.SeatDetails[1].ReceiptNumber, second)
+	}
+
+	var bookingsList []domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingsByUser"), &bookingsList)
+	if len(bookingsList) != 2 {
+		t.Errorf("Expected both bookings of Jim, got %+v", bookingsList)
+	}
+}
+
+func TestBookingWithSeats(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "7", "8"))
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", bookingId), &booking)
+	if len(booking.SeatDetails) != 2 || booking.SeatDetails[0].SeatNumber != "7" || booking.SeatDetails[1].SeatNumber != "8" {
+		t.Errorf("Expected seats 7 and 8 to be booked, got %+v", booking.SeatDetails)
+	}
+	if booking.TotalPrice != 30000 || booking.Currency != "INR" || booking.SeatDetails[1].Price != 15000 {
+		t.Errorf("Expected the booking to be charged 15000 INR a seat, got %+v", booking)
+	}
+
+	// Seat 8 is taken: nothing of the booking of Pam is written, not even seat 9
+	bookings.mustFail(pam, "initBookingWithSeats", "Pam", grudgeShow, "9", "8")
+	bookings.mustFail(pam, "initBookingWithSeats", "Pam", grudgeShow, "9", "9")
+	var bookingsList []domain.Booking
+	unmarshal(t, bookings.mustInvoke(pam, "getBookingsByUser"), &bookingsList)
+	if len(bookingsList) != 0 {
+		t.Errorf("Expected the rejected bookings of Pam not to be written, got %+v", bookingsList)
+	}
+	bookingIdOf(t, bookings.mustInvoke(pam, "initBookingWithSeats", "Pam", grudgeShow, "9"))
+}
+
+// holdIdOf - Hold ID of a successful hold
+func holdIdOf(t *testing.T, payload []byte) string {
+	t.Helper()
+	var hold SeatHold
+	unmarshal(t, payload, &hold)
+	return hold.HoldId
+}
+
+func TestConfirmHold(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	holdId := holdIdOf(t, bookings.mustInvoke(jim, "holdSeats", "Jim", grudgeShow, "2", "5", "6"))
+	bookings.mustFail(pam, "initBookingWithSeats", "Pam", grudgeShow, "6")
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "confirmHold", holdId))
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", bookingId), &booking)
+	if booking.BookedByUser != "Jim" || booking.ShowId != grudgeShow || len(booking.SeatDetails) != 2 || booking.SeatDetails[0].SeatNumber != "5" || booking.TotalPrice != 30000 {
+		t.Errorf("Expected seats 5 and 6 of %s to be booked for Jim at the held price, got %+v", grudgeShow, booking)
+	}
+
+	var hold SeatHold
+	unmarshal(t, bookings.mustInvoke(jim, "getHold", holdId), &hold)
+	if hold.HoldStatus != "Confirmed" || hold.BookingId != bookingId {
+		t.Errorf("Expected the hold to be confirmed into booking %s, got %+v", bookingId, hold)
+	}
+	bookings.mustFail(jim, "confirmHold", holdId)
+	bookings.mustFail(jim, "releaseHold", holdId)
+}
+
+func TestExpiredHold(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	expiredHold := holdIdOf(t, bookings.mustInvoke(jim, "holdSeats", "Jim", grudgeShow, "2", "5", "6"))
+	bookings.network.advance(holdDuration)
+	runningHold := holdIdOf(t, bookings.mustInvoke(pam, "holdSeats", "Pam", grudgeShow, "1", "7"))
+
+	// An expired hold cannot be confirmed, and its seats can be booked before it is swept
+	bookings.mustFail(jim, "confirmHold", expiredHold)
+	bookingIdOf(t, bookings.mustInvoke(pam, "initBookingWithSeats", "Pam", grudgeShow, "6"))
+
+	var sweptHolds []string
+	unmarshal(t, bookings.mustInvoke(jim, "sweepExpiredHolds"), &sweptHolds)
+	if len(sweptHolds) != 1 || sweptHolds[0] != expiredHold {
+		t.Errorf("Expected hold %s to be swept, got %v", expiredHold, sweptHolds)
+	}
+
+	var hold SeatHold
+	unmarshal(t, bookings.mustInvoke(jim, "getHold", expiredHold), &hold)
+	if hold.HoldStatus != "Expired" {
+		t.Errorf("Swept hold is %s, expected Expired", hold.HoldStatus)
+	}
+	unmarshal(t, bookings.mustInvoke(pam, "getHold", runningHold), &hold)
+	if hold.HoldStatus != "Held" {
+		t.Errorf("Running hold is %s after the sweep, expected Held", hold.HoldStatus)
+	}
+	bookingIdOf(t, bookings.mustInvoke(pam, "confirmHold", runningHold))
+
+	unmarshal(t, bookings.mustInvoke(jim, "sweepExpiredHolds"), &sweptHolds)
+	if len(sweptHolds) != 0 {
+		t.Errorf("Expected nothing left to sweep, got %v", sweptHolds)
+	}
+}
+
+// deploySmallShow - Deploys the Bookings chaincode next to a Movies stand-in running a show of The Grudge with 4 seats
+func deploySmallShow(t *testing.T) (*testStub, *testStub) {
+	network := newTestNetwork(t)
+	movies := network.deploy("cc_movies", new(testMovies), jim)
+	movies.mustInvoke(jim, "initMovieDetails", "The Grudge", morning, "4", "4", "False")
+	bookings := network.deploy("cc_bookings", new(BookingChaincode), theaterAdmin, "cc_movies", "mychannel", "Org1MSP")
+	return movies, bookings
+}
+
+// entryIdOf - Entry ID of the message of a successful join
+func entryIdOf(t *testing.T, payload []byte) string {
+	t.Helper()
+	msg := string(payload)
+	if !strings.HasPrefix(msg, "Joined the waitlist successfully. Entry ID: ") {
+		t.Fatalf("Expected to join the waitlist, got %q", msg)
+	}
+	return msg[strings.LastIndex(msg, " ")+1:]
+}
+
+// promote - Runs promoteWaitlist for the show and returns the promotions
+func promote(t *testing.T, bookings *testStub) []domain.WaitlistPromotion {
+	t.Helper()
+	var promotions []domain.WaitlistPromotion
+	unmarshal(t, bookings.mustInvoke(jim, "promoteWaitlist", grudgeShow), &promotions)
+	return promotions
+}
+
+func TestJoinWaitlistOnlyWhenFull(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+
+	bookings.mustFail(pam, "joinWaitlist", "Pam", grudgeShow, "4")
+	bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "1", "2"))
+	bookings.mustFail(pam, "joinWaitlist", "Pam", grudgeShow, "2")
+
+	// Held seats count as taken, so a request larger than what is left is queued
+	holdIdOf(t, bookings.mustInvoke(jim, "holdSeats", "Jim", grudgeShow, "1", "3"))
+	entryIdOf(t, bookings.mustInvoke(pam, "joinWaitlist", "Pam", grudgeShow, "2"))
+	bookings.mustFail(pam, "joinWaitlist", "Pam", grudgeShow, "0")
+}
+
+func TestPromoteWaitlistAfterCancel(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "1", "2", "3", "4"))
+	entryId := entryIdOf(t, bookings.mustInvoke(pam, "joinWaitlist", "Pam", grudgeShow, "2"))
+	if promotions := promote(t, bookings); len(promotions) != 0 {
+		t.Fatalf("Expected nobody to be promoted for a full show, got %+v", promotions)
+	}
+
+	// The cancellation frees the seats, the separate promoteWaitlist transaction finds them free
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+	promotions := promote(t, bookings)
+	if len(promotions) != 1 || promotions[0].EntryId != entryId || len(promotions[0].SeatNumbers) != 2 {
+		t.Fatalf("Expected entry %s to be promoted to 2 seats, got %+v", entryId, promotions)
+	}
+
+	var hold SeatHold
+	unmarshal(t, bookings.mustInvoke(pam, "getHold", promotions[0].HoldId), &hold)
+	if hold.HoldStatus != "Held" || hold.HeldByUser != "Pam" {
+		t.Errorf("Expected the seats to be held for Pam, got %+v", hold)
+	}
+	var entries []WaitlistEntry
+	unmarshal(t, bookings.mustInvoke(pam, "getWaitlist", grudgeShow), &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected the waitlist to be empty after the promotion, got %+v", entries)
+	}
+	bookingIdOf(t, bookings.mustInvoke(pam, "confirmHold", promotions[0].HoldId))
+}
+
+// The seats held for a promoted entry are only seen as taken once the transaction commits, so a single transaction
+// promoting two entries would hand both the same seats. Each promoteWaitlist promotes the head of the queue alone.
+func TestPromoteWaitlistOneEntryPerTransaction(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "1", "2", "3", "4"))
+	firstEntry := entryIdOf(t, bookings.mustInvoke(pam, "joinWaitlist", "Pam", grudgeShow, "2"))
+	secondEntry := entryIdOf(t, bookings.mustInvoke(jim, "joinWaitlist", "Jim", grudgeShow, "2"))
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+
+	first := promote(t, bookings)
+	if len(first) != 1 || first[0].EntryId != firstEntry {
+		t.Fatalf("Expected entry %s alone to be promoted, got %+v", firstEntry, first)
+	}
+	second := promote(t, bookings)
+	if len(second) != 1 || second[0].EntryId != secondEntry {
+		t.Fatalf("Expected entry %s to be promoted next, got %+v", secondEntry, second)
+	}
+
+	seats := map[string]bool{}
+	for _, seatNumber := range append(first[0].SeatNumbers, second[0].SeatNumbers...) {
+		if seats[seatNumber] {
+			t.Errorf("Seat %s is held for both entries: %v and %v", seatNumber, first[0].SeatNumbers, second[0].SeatNumbers)
+		}
+		seats[seatNumber] = true
+	}
+	bookingIdOf(t, bookings.mustInvoke(pam, "confirmHold", first[0].HoldId))
+	bookingIdOf(t, bookings.mustInvoke(jim, "confirmHold", second[0].HoldId))
+}
+
+func TestPromoteWaitlistInOrder(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "1", "2"))
+	bookingIdOf(t, bookings.mustInvoke(jim, "initBookingWithSeats", "Jim", grudgeShow, "3", "4"))
+	largeEntry := entryIdOf(t, bookings.mustInvoke(pam, "joinWaitlist", "Pam", grudgeShow, "3"))
+	entryIdOf(t, bookings.mustInvoke(jim, "joinWaitlist", "Jim", grudgeShow, "1"))
+
+	// The 2 freed seats do not seat the head of the queue, and the smaller entry behind it does not overtake it
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+	if promotions := promote(t, bookings); len(promotions) != 0 {
+		t.Fatalf("Expected the queue to wait for its head, got %+v", promotions)
+	}
+
+	bookings.mustInvoke(pam, "leaveWaitlist", largeEntry)
+	bookings.mustFail(pam, "leaveWaitlist", largeEntry)
+	if promotions := promote(t, bookings); len(promotions) != 1 || promotions[0].WaitingUser != "Jim" {
+		t.Errorf("Expected Jim to be promoted once Pam left, got %+v", promotions)
+	}
+}
+
+func TestSweepPromotesWaitlist(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+
+	holdIdOf(t, bookings.mustInvoke(jim, "holdSeats", "Jim", grudgeShow, "4"))
+	entryIdOf(t, bookings.mustInvoke(pam, "joinWaitlist", "Pam", grudgeShow, "2"))
+
+	// Expired seats already count as free, so the sweep promotes the waitlist itself
+	bookings.network.advance(holdDuration)
+	bookings.mustInvoke(jim, "sweepExpiredHolds")
+	promotedHold := bookings.network.lastTx.id + "_0"
+
+	var entries []WaitlistEntry
+	unmarshal(t, bookings.mustInvoke(pam, "getWaitlist", grudgeShow), &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected the sweep to promote the waitlist, got %+v", entries)
+	}
+	var hold SeatHold
+	unmarshal(t, bookings.mustInvoke(pam, "getHold", promotedHold), &hold)
+	if hold.HeldByUser != "Pam" || len(hold.Seats) != 2 {
+		t.Fatalf("Expected 2 seats to be held for Pam, got %+v", hold)
+	}
+	bookingIdOf(t, bookings.mustInvoke(pam, "confirmHold", promotedHold))
+}
+
+func TestBeverageQuotaBoundary(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookings.mustInvoke(theaterAdmin, "setBeverageQuota", defaultTheaterId, "3")
+	bookings.mustFail(theaterAdmin, "setBeverageQuota", defaultTheaterId, "-1")
+
+	first := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	second := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "2"))
+	third := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "1"))
+
+	var quota BeverageQuota
+	unmarshal(t, bookings.mustInvoke(jim, "getBeverageQuota", defaultTheaterId), &quota)
+	if quota.Requested != 5 || quota.Consumed != 3 || quota.Remaining != 0 {
+		t.Errorf("Expected 5 exchanges asked for and the quota of 3 used up, got %+v", quota)
+	}
+
+	// The quota covers the exchanges in booking order, whatever order they are redeemed in
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", third, third+"_0")
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", second, second+"_1")
+	bookings.mustInvoke(boxOffice, "redeemBeverageExchange", second, second+"_0")
+	bookings.mustInvoke(boxOffice, "redeemBeverageExchange", first, first+"_1")
+	bookings.mustInvoke(boxOffice, "redeemBeverageExchange", first, first+"_0")
+}
+
+func TestCancelBookingReturnsBeverageQuota(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookings.mustInvoke(theaterAdmin, "setBeverageQuota", defaultTheaterId, "2")
+
+	first := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	second := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "1"))
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", second, second+"_0")
+
+	// The exchanges of the cancelled booking go to the bookings made after it
+	bookings.mustInvoke(jim, "cancelBooking", first)
+	bookings.mustInvoke(boxOffice, "redeemBeverageExchange", second, second+"_0")
+	if quota := beverageQuota(t, bookings); quota != 1 {
+		t.Errorf("%d exchanges left after the booking was cancelled, expected 1", quota)
+	}
+}
+
+func TestRedeemBeverageExchange(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookings.mustInvoke(theaterAdmin, "setBeverageQuota", defaultTheaterId, "1")
+
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	bookings.mustInvoke(boxOffice, "redeemBeverageExchange", bookingId, bookingId+"_0")
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", bookingId, bookingId+"_0")
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", bookingId, bookingId+"_1")
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", bookingId, bookingId+"_2")
+
+	// A redeemed exchange is not handed back to the quota by the cancellation
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+	if quota := beverageQuota(t, bookings); quota != 0 {
+		t.Errorf("%d exchanges left after a redeemed booking was cancelled, expected 0", quota)
+	}
+
+	other := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "1"))
+	bookings.mustInvoke(pam, "cancelBooking", other)
+	bookings.mustFail(boxOffice, "redeemBeverageExchange", other, other+"_0")
+}
+
+func TestBeverageQuotaRollsOver(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookings.mustInvoke(theaterAdmin, "setBeverageQuota", defaultTheaterId, "2")
+
+	yesterday := testStartTime.Format(quotaDateFormat)
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	bookings.network.advance(24 * time.Hour)
+	if quota := beverageQuota(t, bookings); quota != 2 {
+		t.Errorf("%d exchanges left on a new day, expected the full quota of 2", quota)
+	}
+
+	// The cancellation gives the exchanges back to the date the booking was made on
+	bookings.mustInvoke(jim, "cancelBooking", bookingId)
+	var quota BeverageQuota
+	unmarshal(t, bookings.mustInvoke(jim, "getBeverageQuota", defaultTheaterId, yesterday), &quota)
+	if quota.Consumed != 0 || quota.Remaining != 2 {
+		t.Errorf("Expected the exchanges of %s to be given back, got %+v", yesterday, quota)
+	}
+	bookings.mustFail(jim, "getBeverageQuota", defaultTheaterId, "01/01/2030")
+}
+
+func TestOwnerFromCertificate(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	// Customers book for themselves under the Owner ID of their certificate
+	bookings.mustFail(jim, "initBookingDetails", "Pam", grudgeShow, "1")
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "", grudgeShow, "1"))
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", bookingId), &booking)
+	if booking.OwnerId != "Org2MSP/Jim" || booking.BookedByUser != "Jim" {
+		t.Errorf("Expected the booking to be owned by Org2MSP/Jim, got %+v", booking)
+	}
+
+	// Other customers can neither see nor change it, the box-office staff and theater admins can
+	for _, function := range []string{"getBookingById", "cancelBooking"} {
+		response := bookings.invoke(pam, function, bookingId)
+		if response.Status != 403 {
+			t.Errorf("Expected %s by Pam to be refused with 403, got %d %s", function, response.Status, response.Message)
+		}
+	}
+	bookings.mustFail(pam, "getBookingsByUser", "Org2MSP/Jim")
+	bookings.mustFail(pam, "transferBooking", bookingId, "Org2MSP", "Pam")
+	bookings.mustInvoke(boxOffice, "getBookingById", bookingId)
+	bookings.mustInvoke(theaterAdmin, "getBookingsByUser", "Org2MSP/Jim")
+
+	// The box office books walk-in customers under its own identity
+	walkIn := bookingIdOf(t, bookings.mustInvoke(boxOffice, "initBookingDetails", "Kevin", grudgeShow, "1"))
+	unmarshal(t, bookings.mustInvoke(boxOffice, "getBookingById", walkIn), &booking)
+	if booking.OwnerId != "Org2MSP/Dwight" || booking.BookedByUser != "Kevin" {
+		t.Errorf("Expected the walk-in booking of Kevin to be owned by the box office, got %+v", booking)
+	}
+
+	// A transferred booking belongs to its new owner alone
+	bookings.mustInvoke(jim, "transferBooking", bookingId, "Org2MSP", "Pam")
+	unmarshal(t, bookings.mustInvoke(pam, "getBookingById", bookingId), &booking)
+	if booking.OwnerId != "Org2MSP/Pam" || booking.BookedByUser != "Pam" {
+		t.Errorf("Expected the booking to be transferred to Org2MSP/Pam, got %+v", booking)
+	}
+	bookings.mustFail(jim, "getBookingById", bookingId)
+	bookings.mustInvoke(pam, "cancelBooking", bookingId)
+}
+
+func TestStaffFunctions(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "1"))
+
+	// Only the theater admins set the quota, the concession stand staff redeem the exchanges
+	bookings.mustFail(jim, "setBeverageQuota", defaultTheaterId, "5")
+	bookings.mustFail(boxOffice, "setBeverageQuota", defaultTheaterId, "5")
+	bookings.mustFail(jim, "redeemBeverageExchange", bookingId, bookingId+"_0")
+	bookings.mustInvoke(theaterAdmin, "redeemBeverageExchange", bookingId, bookingId+"_0")
+}
+
+func TestSetConfig(t *testing.T) {
+	_, bookings := deployBookings(t)
+
+	// The bookings follow the Movies chaincode to its new name, the theater admins stay in charge
+	moved := bookings.network.deploy("cc_movies_v2", new(testMovies), jim)
+	moved.mustInvoke(jim, "initMovieDetails", "The Ring", morning, "10", "10", "False")
+	bookings.mustFail(jim, "setConfig", "cc_movies_v2", testChannel)
+	bookings.mustFail(boxOffice, "setConfig", "cc_movies_v2", testChannel)
+	bookings.mustFail(theaterAdmin, "setConfig", "", testChannel)
+	bookings.mustInvoke(theaterAdmin, "setConfig", "cc_movies_v2", testChannel)
+
+	bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", domain.ShowKey("The Ring", morning), "2"))
+	bookings.mustFail(jim, "initBookingDetails", "Jim", grudgeShow, "2")
+	bookings.mustInvoke(theaterAdmin, "setBeverageQuota", defaultTheaterId, "5")
+
+	// Handing the administration over to another MSP
+	bookings.mustInvoke(theaterAdmin, "setConfig", "cc_movies_v2", testChannel, "Org2MSP")
+	bookings.mustFail(theaterAdmin, "setBeverageQuota", defaultTheaterId, "5")
+	bookings.mustInvoke(jim, "setBeverageQuota", defaultTheaterId, "5")
+}
+
+func TestCancelShowMarksRefundDue(t *testing.T) {
+	movies, bookings := deployBookings(t)
+	jimsBooking := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "2"))
+	pamsBooking := bookingIdOf(t, bookings.mustInvoke(pam, "initBookingDetails", "Pam", grudgeShow, "1"))
+	bookings.mustInvoke(pam, "cancelBooking", pamsBooking)
+
+	// Jim booked before the indexShowBooking index existed, the upgrade adds the booking to it
+	bookings.MockTransactionStart("before-index")
+	indexKey, _ := bookings.CreateCompositeKey(showBookingIndex, []string{"The Grudge", morning, jimsBooking})
+	bookings.MockStub.DelState(indexKey)
+	bookings.MockStub.PutState(bookingConfigKey, []byte(`{"moviesChaincode":"cc_movies","channel":"mychannel","theaterAdminMSP":"Org1MSP"}`))
+	bookings.MockTransactionEnd("before-index")
+	if response := bookings.network.submit(bookings, theaterAdmin, []string{"init"}, true); response.Status != shim.OK {
+		t.Fatalf("Upgrade failed: %s", response.Message)
+	}
+
+	// Only the Movies chaincode marks the bookings of a show it cancels
+	bookings.mustFail(theaterAdmin, "markShowRefundDue", "The Grudge", morning)
+
+	var refunds []domain.RefundDueBooking
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "cancelShow", "The Grudge", morning), &refunds)
+	if len(refunds) != 1 || refunds[0].BookingId != jimsBooking || refunds[0].ReqNmbrOfTickets != 2 || refunds[0].TotalPrice != 30000 {
+		t.Errorf("Expected the booking of Jim to be due a refund of 2 tickets, got %+v", refunds)
+	}
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", jimsBooking), &booking)
+	if booking.BookingStatus != "RefundDue" {
+		t.Errorf("Booking of Jim is %s after the show was cancelled, expected RefundDue", booking.BookingStatus)
+	}
+	unmarshal(t, bookings.mustInvoke(pam, "getBookingById", pamsBooking), &booking)
+	if booking.BookingStatus != "Cancelled" {
+		t.Errorf("Booking of Pam is %s after the show was cancelled, expected it to stay Cancelled", booking.BookingStatus)
+	}
+}
+
+// errorCode - Code of the error envelope of a failed call
+func errorCode(t *testing.T, message string) string {
+	t.Helper()
+	var envelope domain.ChaincodeError
+	unmarshal(t, []byte(message), &envelope)
+	return envelope.Code
+}
+
+func TestErrorCodes(t *testing.T) {
+	_, bookings := deploySmallShow(t)
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "1"))
+
+	for _, call := range []struct {
+		code string
+		args []string
+	}{
+		{domain.CodeNotFound, []string{"getBookingById", "B0"}},
+		{domain.CodeInvalidArgument, []string{"initBookingDetails", "Jim", grudgeShow}},
+		{domain.CodeInvalidArgument, []string{"initBookingDetails", "Jim", "The Grudge", "1"}},
+		{domain.CodeInvalidArgument, []string{"initBookingDetails", "Jim", grudgeShow, "two"}},
+		{domain.CodeConflict, []string{"joinWaitlist", "Jim", grudgeShow, "2"}},
+		{domain.CodeUpstreamFailure, []string{"initBookingDetails", "Jim", grudgeShow, "4"}},
+	} {
+		if code := errorCode(t, bookings.mustFail(jim, call.args...)); code != call.code {
+			t.Errorf("Expected %v to fail with %s, got %s", call.args, call.code, code)
+		}
+	}
+
+	response := bookings.invoke(pam, "cancelBooking", bookingId)
+	if response.Status != 403 || errorCode(t, response.Message) != domain.CodeUnauthorized {
+		t.Errorf("Expected cancelBooking by Pam to fail with 403 %s, got %d %s", domain.CodeUnauthorized, response.Status, response.Message)
+	}
+}
+
+func TestQueryBookings(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "1"))
+
+	// Booking times are stored to the second, so that the queries compare them in order
+	var booking domain.Booking
+	unmarshal(t, bookings.mustInvoke(jim, "getBookingById", bookingId), &booking)
+	if bookingTime, err := time.Parse(time.RFC3339, booking.BookingTime); err != nil || bookingTime.Nanosecond() != 0 || strings.Contains(booking.BookingTime, ".") {
+		t.Errorf("Expected the Booking Time to the second, got %q", booking.BookingTime)
+	}
+
+	// Customers query their own bookings only, the box office everyone's
+	bookings.mustInvoke(jim, "queryBookings", `{"movieName":"The Grudge"}`, "10", "")
+	expected := `{"selector":{"docType":"` + domain.BookingDocType + `","movieName":"The Grudge","ownerId":"Org2MSP/Jim"}}`
+	if query := bookings.queries[len(bookings.queries)-1]; query != expected {
+		t.Errorf("Expected the query %s, got %s", expected, query)
+	}
+	response := bookings.invoke(jim, "queryBookings", `{"ownerId":"Org2MSP/Pam"}`, "10", "")
+	if response.Status != 403 {
+		t.Errorf("Expected a query of Pam's bookings by Jim to be refused with 403, got %d %s", response.Status, response.Message)
+	}
+	bookings.mustInvoke(boxOffice, "queryBookings", `{"bookingTime":{"$gte":"2030-01-01T00:00:00Z"}}`, "10", "")
+	expected = `{"selector":{"bookingTime":{"$gte":"2030-01-01T00:00:00Z"},"docType":"` + domain.BookingDocType + `"}}`
+	if query := bookings.queries[len(bookings.queries)-1]; query != expected {
+		t.Errorf("Expected the query %s, got %s", expected, query)
+	}
+
+	bookings.mustFail(boxOffice, "queryBookings", `{"bookingTime":{"$gte":"2030-01-01T00:00:00.000001Z"}}`, "10", "")
+	bookings.mustFail(boxOffice, "queryBookings", `{"seatDetails":{"$exists":true}}`, "10", "")
+	bookings.mustFail(boxOffice, "queryBookings", `{"reqNmbrOfTickets":{"$in":[1,2]}}`, "10", "")
+}
+
+func TestBookingHistory(t *testing.T) {
+	_, bookings := deployBookings(t)
+	bookingId := bookingIdOf(t, bookings.mustInvoke(jim, "initBookingDetails", "Jim", grudgeShow, "1"))
+	bookings.mustInvoke(jim, "transferBooking", bookingId, "Org2MSP", "Pam")
+	bookings.mustInvoke(pam, "cancelBooking", bookingId)
+
+	// The versions tell who owned the booking when, its latest owner and the staff can read them
+	var versions []domain.BookingVersion
+	unmarshal(t, bookings.mustInvoke(pam, "getBookingHistory", bookingId), &versions)
+	if len(versions) != 3 || versions[0].Booking.OwnerId != "Org2MSP/Jim" || versions[1].Booking.OwnerId != "Org2MSP/Pam" || versions[2].Booking.BookingStatus != "Cancelled" {
+		t.Errorf("Expected the booking by Jim, its transfer to Pam and its cancellation, got %+v", versions)
+	}
+	bookings.mustInvoke(boxOffice, "getBookingHistory", bookingId)
+	response := bookings.invoke(jim, "getBookingHistory", bookingId)
+	if response.Status != 403 {
+		t.Errorf("Expected getBookingHistory by the previous owner to be refused with 403, got %d %s", response.Status, response.Message)
+	}
+	if code := errorCode(t, bookings.mustFail(boxOffice, "getBookingHistory", "B0")); code != domain.CodeNotFound {
+		t.Errorf("Expected the history of an unknown booking to fail with %s, got %s", domain.CodeNotFound, code)
+	}
+}
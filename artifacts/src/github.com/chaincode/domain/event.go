@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Name of the event every successful change sends, apart from the waitlist promotions
+var EventName = "evtsender"
+
+// Name of the event sent when waitlist entries are promoted to holds
+var WaitlistPromotedEventName = "waitlistPromoted"
+
+// Name of the event sent when a show is cancelled
+var ShowCancelledEventName = "showCancelled"
+
+// Event - Payload of the evtsender event. Only the IDs of what the transaction changed are set, Code is always 200.
+type Event struct {
+	Message       string `json:"message"`
+	Movie         string `json:"Movie,omitempty"`
+	MovieId       string `json:"Movie ID,omitempty"`
+	TimeSlot      string `json:"Time Slot,omitempty"`
+	ShowId        string `json:"Show ID,omitempty"`
+	Screen        string `json:"Screen,omitempty"`
+	TotalSeats    string `json:"Total Seats,omitempty"`
+	BookingId     string `json:"Booking ID,omitempty"`
+	HoldId        string `json:"Hold ID,omitempty"`
+	EntryId       string `json:"Entry ID,omitempty"`
+	ReceiptNumber string `json:"Receipt Number,omitempty"`
+	Code          string `json:"code"`
+}
+
+// WaitlistPromotion - A waitlist entry promoted to a Hold, as sent in the waitlistPromoted event
+type WaitlistPromotion struct {
+	EntryId     string   `json:"entryId"`
+	WaitingUser string   `json:"waitingUser"`
+	OwnerId     string   `json:"ownerId"`
+	ShowId      string   `json:"showId"`
+	MovieName   string   `json:"movieName"`
+	TimeSlot    string   `json:"timeSlot"`
+	HoldId      string   `json:"holdId"`
+	SeatNumbers []string `json:"seatNumbers"`
+	ExpiryTime  string   `json:"expiryTime"`
+}
+
+// WaitlistPromotedEvent - Payload of the waitlistPromoted event, with Reason naming what freed the seats
+type WaitlistPromotedEvent struct {
+	Message    string              `json:"message"`
+	Reason     string              `json:"reason"`
+	Promotions []WaitlistPromotion `json:"promotions"`
+	Code       string              `json:"code"`
+}
+
+// RefundDueBooking - A booking of a cancelled show that has to be refunded, as sent in the showCancelled event
+type RefundDueBooking struct {
+	BookingId        string `json:"bookingId"`
+	OwnerId          string `json:"ownerId"`
+	BookedByUser     string `json:"bookedByUser"`
+	ReqNmbrOfTickets int    `json:"reqNmbrOfTickets"`
+	TotalPrice       int    `json:"totalPrice"`
+	Currency         string `json:"currency"`
+}
+
+// ShowCancelledEvent - Payload of the showCancelled event, with every booking of the show now due a refund
+type ShowCancelledEvent struct {
+	Message   string             `json:"message"`
+	ShowId    string             `json:"showId"`
+	MovieId   string             `json:"movieId"`
+	MovieName string             `json:"movieName"`
+	TimeSlot  string             `json:"timeSlot"`
+	Reason    string             `json:"reason"`
+	Refunds   []RefundDueBooking `json:"refunds"`
+	Code      string             `json:"code"`
+}
+
+// SetEvent - Sets the evtsender event of the transaction
+func SetEvent(stub shim.ChaincodeStubInterface, event *Event) error {
+	event.Code = "200"
+	eventAsBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(EventName, eventAsBytes)
+}
+
+// SetWaitlistPromotedEvent - Sets the waitlistPromoted event of the transaction
+func SetWaitlistPromotedEvent(stub shim.ChaincodeStubInterface, event *WaitlistPromotedEvent) error {
+	event.Code = "200"
+	eventAsBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(WaitlistPromotedEventName, eventAsBytes)
+}
+
+// SetShowCancelledEvent - Sets the showCancelled event of the transaction
+func SetShowCancelledEvent(stub shim.ChaincodeStubInterface, event *ShowCancelledEvent) error {
+	event.Code = "200"
+	eventAsBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(ShowCancelledEventName, eventAsBytes)
+}
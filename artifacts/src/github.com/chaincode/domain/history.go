@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// ShowVersion - One version of a show record from the ledger history. Show is nil when the version deleted it.
+type ShowVersion struct {
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Show      *Show     `json:"value"`
+}
+
+// BookingVersion - One version of a booking record from the ledger history. Booking is nil when the version
+// deleted it.
+type BookingVersion struct {
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+	Booking   *Booking  `json:"value"`
+}
+
+// ShowHistory - Every version of the show record at a key, in the order they were committed
+func ShowHistory(stub shim.ChaincodeStubInterface, key string) ([]ShowVersion, error) {
+	versions := []ShowVersion{}
+	err := readHistory(stub, key, func(modification *queryresult.KeyModification, timestamp time.Time) error {
+		version := ShowVersion{TxId: modification.TxId, Timestamp: timestamp, IsDelete: modification.IsDelete}
+		if !modification.IsDelete {
+			show, err := UnmarshalShow(modification.Value)
+			if err != nil {
+				return err
+			}
+			version.Show = show
+		}
+		versions = append(versions, version)
+		return nil
+	})
+	return versions, err
+}
+
+// BookingHistory - Every version of the booking record at a key, in the order they were committed
+func BookingHistory(stub shim.ChaincodeStubInterface, key string) ([]BookingVersion, error) {
+	versions := []BookingVersion{}
+	err := readHistory(stub, key, func(modification *queryresult.KeyModification, timestamp time.Time) error {
+		version := BookingVersion{TxId: modification.TxId, Timestamp: timestamp, IsDelete: modification.IsDelete}
+		if !modification.IsDelete {
+			booking, err := UnmarshalBooking(modification.Value)
+			if err != nil {
+				return err
+			}
+			version.Booking = booking
+		}
+		versions = append(versions, version)
+		return nil
+	})
+	return versions, err
+}
+
+// readHistory - Calls add for each modification of a key, with the time of its transaction in UTC
+func readHistory(stub shim.ChaincodeStubInterface, key string, add func(*queryresult.KeyModification, time.Time) error) error {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var timestamp time.Time
+		if modification.Timestamp != nil {
+			timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+		}
+		err = add(modification, timestamp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
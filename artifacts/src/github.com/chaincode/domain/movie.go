@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Composite key object type of the movie catalog, one key per Movie ID
+var MovieObject = "movie"
+
+// docType of the movies, which rich queries select them by
+var MovieDocType = "movie"
+
+// Movie - A film in the catalog, which shows refer to by MovieId. The Movie ID is a lowercase slug such as
+// inception-2010 and never changes, so the title and the rest can be corrected without touching shows or bookings.
+// NormalizedTitle is the title case folded with its whitespace collapsed, which title lookups and searches match on.
+// RuntimeMinutes is the runtime new shows of the movie are scheduled with, ReleaseDate is YYYY-MM-DD.
+type Movie struct {
+	DocType          string    `json:"docType"`
+	MovieId          string    `json:"movieId"`
+	Title            string    `json:"title"`
+	NormalizedTitle  string    `json:"normalizedTitle"`
+	Genre            string    `json:"genre"`
+	Language         string    `json:"language"`
+	RuntimeMinutes   int       `json:"runtimeMinutes"`
+	Rating           string    `json:"rating"`
+	ReleaseDate      string    `json:"releaseDate"`
+	PosterUrl        string    `json:"posterUrl"`
+	ModificationTime time.Time `json:"modificationTime"`
+}
+
+// MovieKey - Ledger key of a movie of the catalog
+func MovieKey(stub shim.ChaincodeStubInterface, movieId string) (string, error) {
+	return stub.CreateCompositeKey(MovieObject, []string{movieId})
+}
+
+// UnmarshalMovie - Movie from its JSON
+func UnmarshalMovie(movieAsBytes []byte) (*Movie, error) {
+	var movie Movie
+	err := json.Unmarshal(movieAsBytes, &movie)
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
@@ -0,0 +1,173 @@
+// Package domain holds the ledger schema shared by the Movies and Bookings chaincodes: the movie catalog, the shows,
+// their seats and quotes, the bookings, their ledger history, the events and the error envelope, with the helpers
+// building their keys. Each chaincode vendors a copy of this package, vendorDomain.sh at the root of the repository
+// refreshes the copies.
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Composite key index over Movie ID and Time slot, used to find every show of a movie
+var MovieTimeIndex = "indexMovieAndTime"
+
+// Composite key object type of the seat inventory, one key per Movie, Time slot and Seat Number
+var ShowSeatObject = "showSeat"
+
+// docType of the shows, which rich queries select them by
+var ShowDocType = "show"
+
+// Show - A show of a movie in a time slot. MovieId is the movie of the catalog and MovieName its title when the show
+// was created, shows created before the catalog existed use their Movie name as Movie ID. The time slot of a show is
+// its StartTime in RFC 3339 UTC, shows created before StartTime existed keep the free text slot they were given and
+// have no StartTime, RuntimeMinutes or EndTime. updateShow can move the StartTime, the time slot and the Show ID keep
+// the start time the show was created with. ShowStatus is Scheduled, or Cancelled once cancelShow ran; shows created
+// before it existed have none and count as Scheduled.
+// The stored RemainingTickets and HouseFullFlag are as of the last compactShowTickets, getMoviesByName and
+// getShowsByMovie add the ticket changes made since.
+type Show struct {
+	DocType            string      `json:"docType"`
+	ShowId             string      `json:"showId"`
+	MovieId            string      `json:"movieId"`
+	MovieName          string      `json:"movieName"`
+	AvailalbeTimeSlots string      `json:"availalbeTimeSlots"`
+	StartTime          time.Time   `json:"startTime"`
+	RuntimeMinutes     int         `json:"runtimeMinutes"`
+	EndTime            time.Time   `json:"endTime"`
+	TotalTickets       int         `json:"totalTickets"`
+	RemainingTickets   int         `json:"remainingTickets"`
+	HouseFullFlag      string      `json:"houseFullFlag"`
+	ShowStatus         string      `json:"showStatus"`
+	ModificationTime   time.Time   `json:"modificationTime"`
+	ScreenId           string      `json:"screenId"`
+	PriceTable         *PriceTable `json:"priceTable"`
+	TheaterId          string      `json:"theaterId"`
+}
+
+// PriceTable - Ticket prices of a show in the minor unit of the currency (paise, cents). A seat is charged
+// the price of its category, or the base price when its category has no price of its own.
+type PriceTable struct {
+	Currency       string         `json:"currency"`
+	BasePrice      int            `json:"basePrice"`
+	CategoryPrices map[string]int `json:"categoryPrices"`
+}
+
+// Seat - A seat of a show in the seat inventory, Status is one of Free, Held, Booked or Blocked. A Held seat carries
+// the Hold ID in BookingId and is free again once HeldUntil has passed. Blocked seats are left out of the capacity of
+// the show and are not sold.
+type Seat struct {
+	MovieId    string `json:"movieId"`
+	MovieName  string `json:"movieName"`
+	TimeSlot   string `json:"timeSlot"`
+	SeatNumber string `json:"seatNumber"`
+	Category   string `json:"category"`
+	Status     string `json:"status"`
+	BookingId  string `json:"bookingId"`
+	HeldUntil  string `json:"heldUntil"`
+}
+
+// SeatQuote - Price of one seat of a show
+type SeatQuote struct {
+	SeatNumber string `json:"seatNumber"`
+	Category   string `json:"category"`
+	Price      int    `json:"price"`
+}
+
+// ShowQuote - Price of a set of seats of a show, as the Movies chaincode returns it for quotes, reservations and holds
+type ShowQuote struct {
+	ShowId     string      `json:"showId"`
+	MovieName  string      `json:"movieName"`
+	TimeSlot   string      `json:"timeSlot"`
+	TheaterId  string      `json:"theaterId"`
+	Currency   string      `json:"currency"`
+	Seats      []SeatQuote `json:"seats"`
+	TotalPrice int         `json:"totalPrice"`
+}
+
+// ShowKey - Ledger key of a show, every time slot of a Movie is stored separately. The key is also the Show ID.
+func ShowKey(movieId string, timeSlot string) string {
+	return movieId + "_" + NormalizeTimeSlot(timeSlot)
+}
+
+// ParseShowId - Movie ID and time slot of a Show ID. The time slot follows the last underscore, as no time slot
+// has one.
+func ParseShowId(showId string) (string, string, error) {
+	separator := strings.LastIndex(showId, "_")
+	if separator <= 0 || separator == len(showId)-1 {
+		return "", "", NewError(CodeInvalidArgument, "Expecting a Show ID, the Movie ID and start time joined by an underscore, got %q", showId)
+	}
+	return showId[:separator], NormalizeTimeSlot(showId[separator+1:]), nil
+}
+
+// ParseStartTime - Start time of a show from its RFC 3339 form, in UTC to the second
+func ParseStartTime(value string) (time.Time, error) {
+	startTime, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Expecting an RFC 3339 start time such as 2006-01-02T15:04:05+05:30, got %q", value)
+	}
+	return startTime.UTC().Truncate(time.Second), nil
+}
+
+// NormalizeTimeSlot - Time slot of a show given its start time in any RFC 3339 form, so that 09:00+05:30 and
+// 03:30Z name the same show. Free text slots of older shows are returned as they are.
+func NormalizeTimeSlot(timeSlot string) string {
+	startTime, err := ParseStartTime(timeSlot)
+	if err != nil {
+		return timeSlot
+	}
+	return startTime.Format(time.RFC3339)
+}
+
+// MovieTimeIndexKey - Key of a show in the index of the shows of its Movie
+func MovieTimeIndexKey(stub shim.ChaincodeStubInterface, movieId string, timeSlot string) (string, error) {
+	return stub.CreateCompositeKey(MovieTimeIndex, []string{movieId, NormalizeTimeSlot(timeSlot)})
+}
+
+// SeatKey - Ledger key of a seat of the seat inventory
+func SeatKey(stub shim.ChaincodeStubInterface, movieId string, timeSlot string, seatNumber string) (string, error) {
+	return stub.CreateCompositeKey(ShowSeatObject, []string{movieId, NormalizeTimeSlot(timeSlot), seatNumber})
+}
+
+// UnmarshalShow - Show from its JSON, with the Movie ID and Show ID filled in for shows written before they carried them
+func UnmarshalShow(showAsBytes []byte) (*Show, error) {
+	var show Show
+	err := json.Unmarshal(showAsBytes, &show)
+	if err != nil {
+		return nil, err
+	}
+	if show.MovieId == "" {
+		show.MovieId = show.MovieName
+	}
+	if show.ShowId == "" {
+		show.ShowId = ShowKey(show.MovieId, show.AvailalbeTimeSlots)
+	}
+	return &show, nil
+}
+
+// UnmarshalSeat - Seat from its JSON, with the Movie ID filled in for seats written before they carried it
+func UnmarshalSeat(seatAsBytes []byte) (*Seat, error) {
+	var seat Seat
+	err := json.Unmarshal(seatAsBytes, &seat)
+	if err != nil {
+		return nil, err
+	}
+	if seat.MovieId == "" {
+		seat.MovieId = seat.MovieName
+	}
+	return &seat, nil
+}
+
+// UnmarshalShowQuote - ShowQuote from its JSON
+func UnmarshalShowQuote(quoteAsBytes []byte) (*ShowQuote, error) {
+	var quote ShowQuote
+	err := json.Unmarshal(quoteAsBytes, &quote)
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chaincode/domain"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Identities of the tests: an admin of the theater admin MSP, a customer and a theater admin of the customer MSP
+var (
+	theaterAdmin   = newTestCaller("Org1MSP", "admin", map[string]string{"hf.EnrollmentID": "admin"})
+	customer       = newTestCaller("Org2MSP", "jim", map[string]string{"hf.EnrollmentID": "Jim"})
+	roleAdmin      = newTestCaller("Org2MSP", "pam", map[string]string{"hf.EnrollmentID": "Pam", "role": "theaterAdmin"})
+	boxOfficeStaff = newTestCaller("Org2MSP", "dwight", map[string]string{"hf.EnrollmentID": "Dwight", "role": "boxOffice"})
+)
+
+// Movie IDs of the catalog the shows of the tests are scheduled for
+const (
+	grudge    = "the-grudge-2004"
+	godfather = "the-godfather-1972"
+	ring      = "the-ring-2002"
+)
+
+// Start times of the shows of the tests, the day after the test network starts
+const (
+	morning = "2030-01-02T09:00:00Z"
+	noon    = "2030-01-02T12:00:00Z"
+	evening = "2030-01-02T18:00:00Z"
+)
+
+// deployMovies - Deploys the Movies chaincode with screen S1, a single row of seats A1 to A5, and The Grudge (94
+// minutes), The Godfather (175 minutes) and The Ring (90 minutes) in the catalog
+func deployMovies(t *testing.T) (*testNetwork, *testStub) {
+	network := newTestNetwork(t)
+	movies := network.deploy("cc_movies", new(MovieChaincode), theaterAdmin, "Org1MSP")
+	movies.mustInvoke(theaterAdmin, "initScreen", "S1", "Audi 1", `[{"rowLabel":"A","seatsPerRow":5}]`)
+	movies.mustInvoke(theaterAdmin, "registerMovie", grudge, "The Grudge", "94", "Horror", "English", "A", "2004-10-22")
+	movies.mustInvoke(theaterAdmin, "registerMovie", godfather, "The Godfather", "175")
+	movies.mustInvoke(theaterAdmin, "registerMovie", ring, "The Ring", "90")
+	return network, movies
+}
+
+func TestShowsPerTimeSlot(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, evening, "S1")
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":5}]`)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", godfather, morning, "S2")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, evening, "B1", "2")
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, evening), &show)
+	if show.RemainingTickets != 3 {
+		t.Errorf("Evening show of The Grudge has %d tickets left, expected 3", show.RemainingTickets)
+	}
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 5 {
+		t.Errorf("Morning show of The Grudge has %d tickets left, expected 5", show.RemainingTickets)
+	}
+	movies.mustFail(theaterAdmin, "getMoviesByName", grudge, noon)
+
+	var shows []domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getShowsByMovie", grudge), &shows)
+	if len(shows) != 2 || shows[0].AvailalbeTimeSlots != morning || shows[1].AvailalbeTimeSlots != evening {
+		t.Errorf("Expected the morning and evening shows of The Grudge, got %+v", shows)
+	}
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getShowsByMovie", ring), &shows)
+	if len(shows) != 0 {
+		t.Errorf("Expected no shows of The Ring, got %+v", shows)
+	}
+}
+
+func TestMovieCatalog(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+
+	// Correcting the title leaves the Movie ID, and so the shows and their Show IDs, as they are
+	movies.mustInvoke(theaterAdmin, "updateMovie", grudge, "The Grudge (Extended Cut)", "", "", "Japanese")
+	var movie domain.Movie
+	unmarshal(t, movies.mustInvoke(customer, "getMovie", grudge), &movie)
+	if movie.Title != "The Grudge (Extended Cut)" || movie.RuntimeMinutes != 94 || movie.Genre != "Horror" || movie.Language != "Japanese" {
+		t.Errorf("Expected the title and language of The Grudge to be corrected, got %+v", movie)
+	}
+	var shows []domain.Show
+	unmarshal(t, movies.mustInvoke(customer, "getShowsByMovie", grudge), &shows)
+	if len(shows) != 1 || shows[0].ShowId != domain.ShowKey(grudge, morning) {
+		t.Errorf("Expected the morning show to keep its Show ID, got %+v", shows)
+	}
+
+	for _, args := range [][]string{
+		{"inception 2010", "Inception", "148"},
+		{"inception-2010", "Inception", "601"},
+		{"inception-2010", "Inception", "148", "Sci-Fi", "English", "UA", "16/07/2010"},
+		{"inception-2010", "Inception", "148", "", "", "", "", "ftp://posters/inception.jpg"},
+	} {
+		if code := errorCode(t, movies.mustFail(theaterAdmin, append([]string{"registerMovie"}, args...)...)); code != domain.CodeInvalidArgument {
+			t.Errorf("Expected registerMovie %v to fail with %s, got %s", args, domain.CodeInvalidArgument, code)
+		}
+	}
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "registerMovie", grudge, "The Grudge", "94")); code != domain.CodeConflict {
+		t.Errorf("Expected a movie registered twice to fail with %s, got %s", domain.CodeConflict, code)
+	}
+	movies.mustFail(customer, "registerMovie", "inception-2010", "Inception", "148")
+	movies.mustFail(customer, "updateMovie", grudge, "The Grudge 2")
+	movies.mustFail(customer, "getMovie", "inception-2010")
+}
+
+func TestMovieTitleLookup(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	// Titles are matched whatever their case, spacing or Unicode compatibility forms, Movie IDs whatever their case
+	for _, name := range []string{"The Grudge", "the  GRUDGE ", "\uff34\uff48\uff45 \uff27\uff52\uff55\uff44\uff47\uff45", "The-Grudge-2004"} {
+		var movie domain.Movie
+		unmarshal(t, movies.mustInvoke(customer, "getMovie", name), &movie)
+		if movie.MovieId != grudge {
+			t.Errorf("Expected %q to name The Grudge, got %+v", name, movie)
+		}
+	}
+
+	// A show scheduled by title is keyed by the Movie ID
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", "the ring", morning, "S1")
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(customer, "getMoviesByName", "THE RING", morning), &show)
+	if show.ShowId != domain.ShowKey(ring, morning) {
+		t.Errorf("Expected the show of The Ring to be keyed by its Movie ID, got %+v", show)
+	}
+	movies.mustFail(customer, "getShowById", domain.ShowKey("the ring", morning))
+
+	var found []domain.Movie
+	unmarshal(t, movies.mustInvoke(customer, "searchMovies", "the  G"), &found)
+	if len(found) != 2 || found[0].MovieId != godfather || found[1].MovieId != grudge {
+		t.Errorf("Expected The Godfather and The Grudge in title order, got %+v", found)
+	}
+	unmarshal(t, movies.mustInvoke(customer, "searchMovies", "Inception"), &found)
+	if len(found) != 0 {
+		t.Errorf("Expected no movie titled Inception, got %+v", found)
+	}
+
+	// A title shared by two movies has to be narrowed down to a Movie ID
+	movies.mustInvoke(theaterAdmin, "registerMovie", "the-ring-2017", "The Ring", "102")
+	if code := errorCode(t, movies.mustFail(customer, "getMovie", "the ring")); code != domain.CodeConflict {
+		t.Errorf("Expected a title shared by two movies to fail with %s, got %s", domain.CodeConflict, code)
+	}
+	unmarshal(t, movies.mustInvoke(customer, "searchMovies", "The Ring"), &found)
+	if len(found) != 2 {
+		t.Errorf("Expected both movies titled The Ring, got %+v", found)
+	}
+}
+
+func TestShowId(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	// The start time is stored in UTC and ends the Show ID, the end time follows from the runtime
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, "2030-01-02T14:30:00+05:30", "S1"), &show)
+	if show.ShowId != domain.ShowKey(grudge, morning) || show.AvailalbeTimeSlots != morning || !show.EndTime.Equal(show.StartTime.Add(94*time.Minute)) {
+		t.Errorf("Expected the show at %s ending 94 minutes later, got %+v", morning, show)
+	}
+	unmarshal(t, movies.mustInvoke(customer, "getShowById", grudge+"_2030-01-02T14:30:00+05:30"), &show)
+	if show.ShowId != domain.ShowKey(grudge, morning) {
+		t.Errorf("Expected the Show ID with the start time in any offset to name the same show, got %+v", show)
+	}
+	movies.mustFail(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustFail(theaterAdmin, "initMovieDetails", grudge, "9am-12pm", "S1")
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "initMovieDetails", "the-shining-1980", evening, "S1")); code != domain.CodeNotFound {
+		t.Errorf("Expected a show of a movie missing from the catalog to fail with %s, got %s", domain.CodeNotFound, code)
+	}
+	movies.mustFail(customer, "getShowById", grudge)
+
+	// Shows written before they carried a Show ID or Movie ID are keyed by their Movie name, and read with the IDs their
+	// key gives them
+	movies.MockTransactionStart("legacy")
+	movies.MockStub.PutState(domain.ShowKey("The Ring", "9am-12pm"), []byte(`{"docType":"show","movieName":"The Ring","availalbeTimeSlots":"9am-12pm","totalTickets":5,"remainingTickets":5}`))
+	movies.MockTransactionEnd("legacy")
+	unmarshal(t, movies.mustInvoke(customer, "getShowById", "The Ring_9am-12pm"), &show)
+	if show.ShowId != "The Ring_9am-12pm" || show.MovieId != "The Ring" || show.RemainingTickets != 5 {
+		t.Errorf("Expected the legacy show The Ring_9am-12pm, got %+v", show)
+	}
+}
+
+func TestScreenSchedule(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":5}]`)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+
+	// The morning show ends at 10:34, the Screen is free again after the 15 minutes of cleaning
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "initMovieDetails", ring, "2030-01-02T10:48:59Z", "S1")); code != domain.CodeConflict {
+		t.Errorf("Expected a show starting within the cleaning buffer to fail with %s, got %s", domain.CodeConflict, code)
+	}
+	movies.mustFail(theaterAdmin, "initMovieDetails", ring, "2030-01-02T07:30:00Z", "S1")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", ring, "2030-01-02T10:49:00Z", "S1")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", godfather, "2030-01-02T10:00:00Z", "S2")
+
+	// A show running past midnight keeps the Screen of the next day
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", godfather, "2030-01-02T23:00:00Z", "S1")
+	movies.mustFail(theaterAdmin, "initMovieDetails", grudge, "2030-01-03T02:00:00Z", "S1")
+
+	movies.mustFail(customer, "setCleaningBuffer", "0")
+	movies.mustFail(theaterAdmin, "setCleaningBuffer", "-1")
+	movies.mustInvoke(theaterAdmin, "setCleaningBuffer", "0")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, "2030-01-03T01:55:00Z", "S1")
+}
+
+func TestListShows(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, evening, "S1")
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":5}]`)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", godfather, morning, "S2")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", godfather, morning, "B1", "5")
+
+	// The pages follow each other in Movie name and Time slot order, the last one without a bookmark
+	var page ShowPage
+	unmarshal(t, movies.mustInvoke(customer, "listShows", "2", ""), &page)
+	if len(page.Shows) != 2 || page.Shows[0].MovieName != "The Godfather" || page.Shows[1].AvailalbeTimeSlots != morning || page.Bookmark == "" {
+		t.Fatalf("Expected The Godfather and the morning show of The Grudge with a bookmark, got %+v", page)
+	}
+	if page.Shows[0].RemainingTickets != 0 || page.Shows[0].HouseFullFlag != "True" {
+		t.Errorf("Expected The Godfather to be listed house full, got %+v", page.Shows[0])
+	}
+	unmarshal(t, movies.mustInvoke(customer, "listShows", "2", page.Bookmark), &page)
+	if len(page.Shows) != 1 || page.Shows[0].AvailalbeTimeSlots != evening || page.Bookmark != "" {
+		t.Errorf("Expected the evening show of The Grudge on the last page, got %+v", page)
+	}
+
+	// Leaving out the house-full shows keeps the page size, so the first page holds one show
+	unmarshal(t, movies.mustInvoke(customer, "listShows", "2", "", "True"), &page)
+	if len(page.Shows) != 1 || page.Shows[0].MovieName != "The Grudge" || page.FetchedRecordsCount != 2 {
+		t.Errorf("Expected a single show of The Grudge out of 2 fetched, got %+v", page)
+	}
+	movies.mustFail(customer, "listShows", "0", "")
+	movies.mustFail(customer, "listShows", "101", "")
+}
+
+func TestQueryShows(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	// Times are selected in UTC to the second, the way they are stored
+	movies.mustInvoke(customer, "queryShows", `{"movieId":"`+grudge+`","modificationTime":{"$gte":"2030-01-01T13:30:00+05:30"}}`, "10", "")
+	expected := `{"selector":{"docType":"` + domain.ShowDocType + `","modificationTime":{"$gte":"2030-01-01T08:00:00Z"},"movieId":"` + grudge + `"}}`
+	if query := movies.queries[len(movies.queries)-1]; query != expected {
+		t.Errorf("Expected the query %s, got %s", expected, query)
+	}
+
+	for _, selector := range []string{
+		`{"remainingTickets":{"$gt":5}}`,
+		`{"$or":[{"movieId":"` + grudge + `"},{"movieId":"` + ring + `"}]}`,
+		`{"movieId":{"$regex":"grudge"}}`,
+		`{"modificationTime":{"$gte":"2030-01-01T08:00:00.5Z"}}`,
+		`{"modificationTime":"yesterday"}`,
+	} {
+		if code := errorCode(t, movies.mustFail(customer, "queryShows", selector, "10", "")); code != domain.CodeInvalidArgument {
+			t.Errorf("Expected the selector %s to be rejected with %s, got %s", selector, domain.CodeInvalidArgument, code)
+		}
+	}
+	movies.mustFail(customer, "queryShows", "{}", "0", "")
+	if len(movies.queries) != 1 {
+		t.Errorf("Expected the rejected selectors not to be queried, got %v", movies.queries)
+	}
+}
+
+func TestShowHistory(t *testing.T) {
+	network, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	created := network.lastTx
+	movies.mustInvoke(theaterAdmin, "setShowPricing", domain.ShowKey(grudge, morning), "INR", "15000", `{}`)
+	priced := network.lastTx
+
+	// Every version of the show record, oldest first, with the transaction that wrote it
+	var versions []domain.ShowVersion
+	unmarshal(t, movies.mustInvoke(boxOfficeStaff, "getShowHistory", domain.ShowKey(grudge, morning)), &versions)
+	if len(versions) != 2 || versions[0].TxId != created.id || versions[1].TxId != priced.id {
+		t.Fatalf("Expected the versions written by %s and %s, got %+v", created.id, priced.id, versions)
+	}
+	if !versions[1].Timestamp.Equal(priced.time) || versions[0].Show.PriceTable != nil || versions[1].Show.PriceTable == nil {
+		t.Errorf("Expected the show to be priced by the second version at %s, got %+v", priced.time, versions)
+	}
+
+	movies.mustInvoke(roleAdmin, "getShowHistory", domain.ShowKey(grudge, morning))
+	response := movies.invoke(customer, "getShowHistory", domain.ShowKey(grudge, morning))
+	if response.Status != 403 {
+		t.Errorf("Expected getShowHistory by a customer to be refused with 403, got %d %s", response.Status, response.Message)
+	}
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "getShowHistory", domain.ShowKey(ring, morning))); code != domain.CodeNotFound {
+		t.Errorf("Expected the history of an unknown show to fail with %s, got %s", domain.CodeNotFound, code)
+	}
+}
+
+func TestShowCreatedOnce(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2")
+	movies.mustFail(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustFail(theaterAdmin, "initMovieDetails", grudge, noon, "S2")
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 3 {
+		t.Errorf("Morning show of The Grudge has %d tickets left after it was created again, expected 3", show.RemainingTickets)
+	}
+}
+
+func TestModificationTimeFromTransaction(t *testing.T) {
+	network, movies := deployMovies(t)
+
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	modificationTime := network.lastTx.time
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if !show.ModificationTime.Equal(modificationTime) {
+		t.Errorf("Show was modified at %s, expected the transaction time %s", show.ModificationTime, modificationTime)
+	}
+}
+
+// seatStatus - Status of a seat of a show and the Booking ID it is booked for
+func seatStatus(t *testing.T, movies *testStub, movieName string, timeSlot string, seatNumber string) (string, string) {
+	t.Helper()
+	var seats []domain.Seat
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getShowSeats", domain.ShowKey(movieName, timeSlot)), &seats)
+	for _, seat := range seats {
+		if seat.SeatNumber == seatNumber {
+			return seat.Status, seat.BookingId
+		}
+	}
+	t.Fatalf("Seat %s of %s at %s does not exist", seatNumber, movieName, timeSlot)
+	return "", ""
+}
+
+func TestReserveShowSeats(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+
+	var quote domain.ShowQuote
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2", "A2", "A3"), &quote)
+	if len(quote.Seats) != 2 || quote.Seats[0].SeatNumber != "A2" || quote.Seats[1].SeatNumber != "A3" {
+		t.Errorf("Expected seats A2 and A3 to be reserved, got %+v", quote.Seats)
+	}
+
+	// A booking taking a seat already booked, a seat requested twice or a missing seat is rejected as a whole
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "2", "A4", "A3")
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "2", "A4", "A4")
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "2", "A4", "A9")
+	if status, _ := seatStatus(t, movies, grudge, morning, "A4"); status != "Free" {
+		t.Errorf("Seat A4 is %s after the rejected bookings, expected Free", status)
+	}
+
+	// Without Seat Numbers the first free seats side by side are booked
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "2"), &quote)
+	if len(quote.Seats) != 2 || quote.Seats[0].SeatNumber != "A4" || quote.Seats[1].SeatNumber != "A5" {
+		t.Errorf("Expected seats A4 and A5 to be reserved, got %+v", quote.Seats)
+	}
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 1 {
+		t.Errorf("The show has %d tickets left after 4 were reserved, expected 1", show.RemainingTickets)
+	}
+}
+
+// reserveSeatNumbers - Seat Numbers reserved for a booking made without Seat Numbers
+func reserveSeatNumbers(t *testing.T, movies *testStub, bookingId string, reqNmbrOfTickets string) []string {
+	t.Helper()
+	var quote domain.ShowQuote
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, bookingId, reqNmbrOfTickets), &quote)
+	seatNumbers := []string{}
+	for _, seat := range quote.Seats {
+		seatNumbers = append(seatNumbers, seat.SeatNumber)
+	}
+	return seatNumbers
+}
+
+func TestSeatsSideBySide(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":4,"blocked":[3]},{"rowLabel":"B","seatsPerRow":3}]`)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S2")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "1", "A1")
+
+	// A2 and A4 are free but split by the blocked position, so the party is seated in row B
+	var quote domain.ShowQuote
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "quoteShowSeats", grudge, morning, "2"), &quote)
+	if seatNumbers := reserveSeatNumbers(t, movies, "B2", "2"); fmt.Sprint(seatNumbers) != "[B1 B2]" {
+		t.Errorf("Expected seats B1 and B2 to be reserved, got %v", seatNumbers)
+	}
+	if len(quote.Seats) != 2 || quote.Seats[0].SeatNumber != "B1" || quote.Seats[1].SeatNumber != "B2" {
+		t.Errorf("Expected the quote to cover the seats booked after it, got %+v", quote.Seats)
+	}
+
+	// With no two seats side by side left, the first free seats of the show are booked
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B3", "1", "B3")
+	if seatNumbers := reserveSeatNumbers(t, movies, "B4", "2"); fmt.Sprint(seatNumbers) != "[A2 A4]" {
+		t.Errorf("Expected seats A2 and A4 to be reserved, got %v", seatNumbers)
+	}
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B5", "1")
+}
+
+func TestCompactShowTickets(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "1")
+
+	// Bookings leave the show record alone and write a ticket count change each
+	var show domain.Show
+	unmarshal(t, movies.State[domain.ShowKey(grudge, morning)], &show)
+	if show.RemainingTickets != 5 {
+		t.Errorf("The show record has %d tickets left before the compaction, expected 5", show.RemainingTickets)
+	}
+
+	movies.mustInvoke(theaterAdmin, "compactShowTickets", domain.ShowKey(grudge, morning))
+	unmarshal(t, movies.State[domain.ShowKey(grudge, morning)], &show)
+	if show.RemainingTickets != 2 {
+		t.Errorf("The show record has %d tickets left after the compaction, expected 2", show.RemainingTickets)
+	}
+	deltas, _ := movies.GetStateByPartialCompositeKey(showTicketDeltaObject, []string{grudge, morning})
+	if deltas.HasNext() {
+		t.Errorf("Expected the ticket count changes to be deleted by the compaction")
+	}
+
+	movies.mustInvoke(theaterAdmin, "releaseShowSeats", grudge, morning, "B1", "A1", "A2")
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 4 || show.HouseFullFlag != "False" {
+		t.Errorf("Expected 4 tickets left after the release, got %+v", show)
+	}
+	movies.mustFail(theaterAdmin, "compactShowTickets", domain.ShowKey(grudge, noon))
+}
+
+// A ticket count that does not add up with the capacity of the show is reported instead of being clamped
+func TestTicketCountDrift(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+
+	movies.MockTransactionStart("drift")
+	deltaKey, _ := movies.CreateCompositeKey(showTicketDeltaObject, []string{grudge, morning, "drift"})
+	movies.MockStub.PutState(deltaKey, []byte(`{"movieId":"`+grudge+`","timeSlot":"`+morning+`","deltaId":"drift","change":-6}`))
+	movies.MockTransactionEnd("drift")
+
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "getMoviesByName", grudge, morning)); code != domain.CodeInternal {
+		t.Errorf("Expected the drifted show to fail with %s, got %s", domain.CodeInternal, code)
+	}
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "compactShowTickets", domain.ShowKey(grudge, morning))); code != domain.CodeInternal {
+		t.Errorf("Expected the compaction of the drifted show to fail with %s, got %s", domain.CodeInternal, code)
+	}
+}
+
+func TestReleaseShowSeats(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2", "A2", "A3")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "1", "A4")
+
+	// Seats of another booking are left alone and a seat named twice is released once
+	var seats []domain.Seat
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "releaseShowSeats", grudge, morning, "B1", "A2", "A2", "A4"), &seats)
+	if len(seats) != 1 || seats[0].SeatNumber != "A2" {
+		t.Errorf("Expected seat A2 to be released, got %+v", seats)
+	}
+	if status, bookingId := seatStatus(t, movies, grudge, morning, "A4"); status != "Booked" || bookingId != "B2" {
+		t.Errorf("Seat A4 is %s for %q after B1 was released, expected Booked for B2", status, bookingId)
+	}
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 3 {
+		t.Errorf("The show has %d tickets left after a seat was released, expected 3", show.RemainingTickets)
+	}
+}
+
+func TestScreenLayout(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":4,"aisles":[2],"blocked":[1]},{"rowLabel":"B","seatsPerRow":3,"category":"Premium"}]`)
+	var screen Screen
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getScreen", "S2"), &screen)
+	if screen.TotalSeats != 6 || screen.Rows[0].Category != "Standard" {
+		t.Errorf("Expected 6 seats with row A Standard, got %+v", screen)
+	}
+
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S2")
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.TotalTickets != 6 || show.RemainingTickets != 6 {
+		t.Errorf("Expected 6 tickets from the layout of S2, got %d of %d", show.RemainingTickets, show.TotalTickets)
+	}
+	var seats []domain.Seat
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getShowSeats", domain.ShowKey(grudge, morning)), &seats)
+	if len(seats) != 6 || seats[0].SeatNumber != "A2" || seats[5].SeatNumber != "B3" || seats[5].Category != "Premium" {
+		t.Errorf("Expected seats A2 to B3 without the blocked seat A1, got %+v", seats)
+	}
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "1", "A1")
+
+	// Layouts with duplicate rows, positions outside of a row or no sellable seat are rejected
+	movies.mustFail(theaterAdmin, "initScreen", "S3", "Audi 3", `[{"rowLabel":"A","seatsPerRow":4},{"rowLabel":"A","seatsPerRow":4}]`)
+	movies.mustFail(theaterAdmin, "initScreen", "S3", "Audi 3", `[{"rowLabel":"A","seatsPerRow":4,"aisles":[5]}]`)
+	movies.mustFail(theaterAdmin, "initScreen", "S3", "Audi 3", `[{"rowLabel":"A","seatsPerRow":1,"blocked":[1]}]`)
+	movies.mustFail(theaterAdmin, "getScreen", "S3")
+}
+
+func TestShowPricing(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":2},{"rowLabel":"B","seatsPerRow":2,"category":"Premium"},{"rowLabel":"C","seatsPerRow":2,"category":"Recliner"}]`)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S2")
+
+	// Shows without a price table are free
+	var quote domain.ShowQuote
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "quoteShowSeats", grudge, morning, "1"), &quote)
+	if quote.TotalPrice != 0 || quote.Currency != "" {
+		t.Errorf("Expected a free show, got %+v", quote)
+	}
+
+	movies.mustInvoke(theaterAdmin, "setShowPricing", domain.ShowKey(grudge, morning), "inr", "15000", `{"Premium":25000}`)
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "quoteShowSeats", grudge, morning, "3", "A1", "B2", "C1"), &quote)
+	if quote.Currency != "INR" || quote.TotalPrice != 55000 || quote.Seats[1].Price != 25000 || quote.Seats[2].Price != 15000 {
+		t.Errorf("Expected Premium seats at 25000 and the others at the base price of 15000 INR, got %+v", quote)
+	}
+
+	// A quote books nothing, and names the seats a booking would get
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "quoteShowSeats", grudge, morning, "2"), &quote)
+	if quote.Seats[0].SeatNumber != "A1" || quote.Seats[1].SeatNumber != "A2" || quote.TotalPrice != 30000 {
+		t.Errorf("Expected seats A1 and A2 for 30000, got %+v", quote)
+	}
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2"), &quote)
+	if quote.Seats[0].SeatNumber != "A1" || quote.TotalPrice != 30000 {
+		t.Errorf("Expected the booking to match the quote, got %+v", quote)
+	}
+
+	movies.mustFail(theaterAdmin, "setShowPricing", domain.ShowKey(grudge, morning), "RUPEE", "15000", "")
+	movies.mustFail(theaterAdmin, "setShowPricing", domain.ShowKey(grudge, morning), "INR", "-1", "")
+	movies.mustFail(theaterAdmin, "setShowPricing", domain.ShowKey(grudge, morning), "INR", "15000", `{"Premium":-5}`)
+}
+
+func TestHeldSeats(t *testing.T) {
+	network, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	heldUntil := network.now.Add(5 * time.Minute).Format(time.RFC3339)
+
+	movies.mustInvoke(theaterAdmin, "holdShowSeats", grudge, morning, "H1", heldUntil, "2", "A1", "A2")
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 3 {
+		t.Errorf("The show has %d tickets left with 2 seats held, expected 3", show.RemainingTickets)
+	}
+
+	// Held seats are not sold to anyone else until the hold expires
+	movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "1", "A1")
+	var quote domain.ShowQuote
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "1"), &quote)
+	if quote.Seats[0].SeatNumber != "A3" {
+		t.Errorf("Expected the first seat that is not held to be booked, got %+v", quote.Seats)
+	}
+
+	network.advance(5 * time.Minute)
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 4 {
+		t.Errorf("The show has %d tickets left after the hold expired, expected 4", show.RemainingTickets)
+	}
+	movies.mustFail(theaterAdmin, "confirmHeldSeats", grudge, morning, "H1", "B2", "A1", "A2")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "1", "A1")
+	if status, bookingId := seatStatus(t, movies, grudge, morning, "A1"); status != "Booked" || bookingId != "B2" {
+		t.Errorf("Seat A1 is %s for %q, expected the expired seat to be booked for B2", status, bookingId)
+	}
+}
+
+func TestConfirmHeldSeats(t *testing.T) {
+	network, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	heldUntil := network.now.Add(5 * time.Minute).Format(time.RFC3339)
+	movies.mustInvoke(theaterAdmin, "holdShowSeats", grudge, morning, "H1", heldUntil, "2", "A1", "A2")
+	movies.mustInvoke(theaterAdmin, "holdShowSeats", grudge, morning, "H2", heldUntil, "1", "A3")
+
+	// Seats held for another Hold ID fail the whole confirmation
+	movies.mustFail(theaterAdmin, "confirmHeldSeats", grudge, morning, "H1", "B1", "A1", "A3")
+	movies.mustInvoke(theaterAdmin, "confirmHeldSeats", grudge, morning, "H1", "B1", "A1", "A2")
+	movies.mustInvoke(theaterAdmin, "releaseHeldSeats", grudge, morning, "H2", "A3", "A1")
+
+	if status, bookingId := seatStatus(t, movies, grudge, morning, "A1"); status != "Booked" || bookingId != "B1" {
+		t.Errorf("Seat A1 is %s for %q, expected Booked for B1", status, bookingId)
+	}
+	if status, _ := seatStatus(t, movies, grudge, morning, "A3"); status != "Free" {
+		t.Errorf("Seat A3 is %s after its hold was released, expected Free", status)
+	}
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "getMoviesByName", grudge, morning), &show)
+	if show.RemainingTickets != 3 {
+		t.Errorf("The show has %d tickets left after 2 held seats were booked, expected 3", show.RemainingTickets)
+	}
+}
+
+func TestTheaterAdminFunctions(t *testing.T) {
+	_, movies := deployMovies(t)
+
+	for _, caller := range []*testCaller{customer, boxOfficeStaff} {
+		response := movies.invoke(caller, "initMovieDetails", grudge, morning, "S1")
+		if response.Status != 403 {
+			t.Errorf("Expected initMovieDetails by %s to be refused with 403, got %d %s", caller.name, response.Status, response.Message)
+		}
+		movies.mustFail(caller, "initScreen", "S2", "Audi 2", `[{"rowLabel":"A","seatsPerRow":5}]`)
+	}
+
+	// The role attribute makes a theater admin of any MSP, the read functions stay open to everyone
+	movies.mustInvoke(roleAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(customer, "getMoviesByName", grudge, morning)
+	movies.mustInvoke(customer, "getShowSeats", domain.ShowKey(grudge, morning))
+	movies.mustFail(customer, "setShowPricing", domain.ShowKey(grudge, morning), "INR", "15000")
+	movies.mustFail(customer, "compactShowTickets", domain.ShowKey(grudge, morning))
+}
+
+// testBookings - Stand-in of the Bookings chaincode passing its calls on to the Movies chaincode. The Booking IDs of
+// the seats it reserves are kept against the show, and markShowRefundDue returns them.
+type testBookings struct {
+}
+
+func (b *testBookings) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (b *testBookings) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if function == "markShowRefundDue" {
+		refunds := []domain.RefundDueBooking{}
+		resultsIterator, _ := stub.GetStateByPartialCompositeKey("booking", args)
+		defer resultsIterator.Close()
+		for resultsIterator.HasNext() {
+			responseRange, _ := resultsIterator.Next()
+			_, keyParts, _ := stub.SplitCompositeKey(responseRange.Key)
+			refunds = append(refunds, domain.RefundDueBooking{BookingId: keyParts[2]})
+		}
+		refundsAsBytes, _ := json.Marshal(refunds)
+		return shim.Success(refundsAsBytes)
+	}
+
+	response := stub.InvokeChaincode("cc_movies", stub.GetArgs(), "")
+	if function == "reserveShowSeats" && response.Status == shim.OK {
+		bookingKey, _ := stub.CreateCompositeKey("booking", args[:3])
+		stub.PutState(bookingKey, []byte(args[3]))
+	}
+	return response
+}
+
+func TestSeatInventoryThroughBookings(t *testing.T) {
+	network, movies := deployMovies(t)
+	bookings := network.deploy("cc_bookings", new(testBookings), theaterAdmin)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+
+	// A customer changes the seat inventory only through a booking sent to the Bookings chaincode
+	movies.mustFail(customer, "reserveShowSeats", grudge, morning, "B1", "1", "A1")
+	movies.mustFail(customer, "releaseShowSeats", grudge, morning, "B1", "A1")
+	bookings.mustInvoke(customer, "reserveShowSeats", grudge, morning, "B1", "1", "A1")
+	if status, bookingId := seatStatus(t, movies, grudge, morning, "A1"); status != "Booked" || bookingId != "B1" {
+		t.Errorf("Expected seat A1 to be booked for B1, got %s %s", status, bookingId)
+	}
+	bookings.mustFail(customer, "initMovieDetails", grudge, evening, "S1")
+}
+
+func TestUpdateShow(t *testing.T) {
+	_, movies := deployMovies(t)
+	showId := domain.ShowKey(grudge, morning)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "3")
+
+	// The capacity cannot drop below the seats sold
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "updateShow", showId, "", "", "2")); code != domain.CodeConflict {
+		t.Errorf("Expected a capacity below the seats sold to fail with %s, got %s", domain.CodeConflict, code)
+	}
+	movies.mustFail(theaterAdmin, "updateShow", showId, "", "", "6")
+	movies.mustFail(customer, "updateShow", showId, "", "", "4")
+
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "updateShow", showId, "", "", "4"), &show)
+	if show.TotalTickets != 4 || show.RemainingTickets != 1 {
+		t.Errorf("Expected 1 of 4 tickets left, got %d of %d", show.RemainingTickets, show.TotalTickets)
+	}
+
+	// A rescheduled show keeps its Show ID and frees its old time on the Screen
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "updateShow", showId, evening, "", ""), &show)
+	if show.ShowId != showId || show.StartTime.Format(time.RFC3339) != evening || show.RemainingTickets != 1 {
+		t.Errorf("Expected show %s to start in the evening with 1 ticket left, got %+v", showId, show)
+	}
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", ring, morning, "S1")
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "initMovieDetails", godfather, evening, "S1")); code != domain.CodeConflict {
+		t.Errorf("Expected a show over the rescheduled one to fail with %s, got %s", domain.CodeConflict, code)
+	}
+}
+
+func TestCancelShow(t *testing.T) {
+	network, movies := deployMovies(t)
+	bookings := network.deploy("cc_bookings", new(testBookings), theaterAdmin)
+	showId := domain.ShowKey(grudge, morning)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	bookings.mustInvoke(customer, "reserveShowSeats", grudge, morning, "B1", "2")
+
+	movies.mustFail(customer, "cancelShow", showId)
+	var show domain.Show
+	unmarshal(t, movies.mustInvoke(theaterAdmin, "cancelShow", showId, "Projector failure"), &show)
+	if show.ShowStatus != "Cancelled" {
+		t.Errorf("Show is %s after it was cancelled", show.ShowStatus)
+	}
+	var event domain.ShowCancelledEvent
+	unmarshal(t, movies.event.Payload, &event)
+	if movies.event.EventName != domain.ShowCancelledEventName || event.Reason != "Projector failure" || len(event.Refunds) != 1 || event.Refunds[0].BookingId != "B1" {
+		t.Errorf("Expected a showCancelled event with the refund of B1, got %s %+v", movies.event.EventName, event)
+	}
+
+	// The cancelled show sells no more seats and leaves its time on the Screen free
+	bookings.mustFail(customer, "reserveShowSeats", grudge, morning, "B2", "1")
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "cancelShow", showId)); code != domain.CodeConflict {
+		t.Errorf("Expected a second cancellation to fail with %s, got %s", domain.CodeConflict, code)
+	}
+	movies.mustFail(theaterAdmin, "updateShow", showId, evening, "", "")
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", ring, morning, "S1")
+}
+
+// errorCode - Code of the error envelope of a failed call
+func errorCode(t *testing.T, message string) string {
+	t.Helper()
+	var envelope domain.ChaincodeError
+	unmarshal(t, []byte(message), &envelope)
+	return envelope.Code
+}
+
+func TestErrorCodes(t *testing.T) {
+	_, movies := deployMovies(t)
+	movies.mustInvoke(theaterAdmin, "initMovieDetails", grudge, morning, "S1")
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B1", "2", "A1", "A2")
+
+	for _, call := range []struct {
+		code string
+		args []string
+	}{
+		{domain.CodeNotFound, []string{"getMoviesByName", ring, morning}},
+		{domain.CodeInvalidArgument, []string{"reserveShowSeats", grudge, morning, "B2", "two"}},
+		{domain.CodeInvalidArgument, []string{"reserveShowSeats", grudge, morning, "B2", "2", "A4", "A4"}},
+		{domain.CodeConflict, []string{"reserveShowSeats", grudge, morning, "B2", "1", "A2"}},
+		{domain.CodeInsufficientSeats, []string{"reserveShowSeats", grudge, morning, "B2", "4"}},
+		{domain.CodeInvalidArgument, []string{"noSuchFunction"}},
+	} {
+		if code := errorCode(t, movies.mustFail(theaterAdmin, call.args...)); code != call.code {
+			t.Errorf("Expected %v to fail with %s, got %s", call.args, call.code, code)
+		}
+	}
+
+	movies.mustInvoke(theaterAdmin, "reserveShowSeats", grudge, morning, "B2", "3")
+	if code := errorCode(t, movies.mustFail(theaterAdmin, "reserveShowSeats", grudge, morning, "B3", "1")); code != domain.CodeSoldOut {
+		t.Errorf("Expected a booking of the full show to fail with %s, got %s", domain.CodeSoldOut, code)
+	}
+	response := movies.invoke(customer, "initMovieDetails", ring, morning, "S1")
+	if response.Status != 403 || errorCode(t, response.Message) != domain.CodeUnauthorized {
+		t.Errorf("Expected initMovieDetails by a customer to fail with 403 %s, got %d %s", domain.CodeUnauthorized, response.Status, response.Message)
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Channel the chaincodes of the tests are deployed on
+var testChannel = "mychannel"
+
+// Time of the first transaction of a test network, every transaction is a second after the previous one
+var testStartTime = time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC)
+
+// testNetwork - Chaincodes of a test deployed on one channel, with the clock their transactions are stamped by
+type testNetwork struct {
+	t      *testing.T
+	now    time.Time
+	txSeq  int
+	lastTx *testTx
+	stubs  map[string]*testStub
+}
+
+// testStub - MockStub of a chaincode of the test network. A bare MockStub has no creator, reads the writes of the
+// transaction in progress and commits failed transactions. testStub supplies the caller's certificate and the signed
+// proposal, and keeps the writes of a transaction apart until it succeeds: reads see the state committed by earlier
+// transactions only, as on a peer, so a transaction relying on its own writes fails here as it would on a peer.
+type testStub struct {
+	*shim.MockStub
+	network *testNetwork
+	cc      shim.Chaincode
+	args    [][]byte
+	tx      *testTx
+	writes  map[string][]byte
+	history map[string][]*queryresult.KeyModification
+	event   *pb.ChaincodeEvent
+	queries []string
+}
+
+// testTx - Transaction in progress, shared by the chaincodes it calls
+type testTx struct {
+	id       string
+	time     time.Time
+	caller   *testCaller
+	proposal *pb.SignedProposal
+}
+
+// testCaller - Identity submitting transactions, with the attributes of its certificate
+type testCaller struct {
+	mspId   string
+	name    string
+	attrs   map[string]string
+	creator []byte
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	return &testNetwork{t: t, now: testStartTime, stubs: map[string]*testStub{}}
+}
+
+// deploy - Instantiates a chaincode under a name, calling its Init with the args
+func (n *testNetwork) deploy(name string, cc shim.Chaincode, caller *testCaller, args ...string) *testStub {
+	n.t.Helper()
+	s := &testStub{MockStub: shim.NewMockStub(name, cc), network: n, cc: cc, history: map[string][]*queryresult.KeyModification{}}
+	s.ChannelID = testChannel
+	n.stubs[name] = s
+
+	response := n.submit(s, caller, append([]string{"init"}, args...), true)
+	if response.Status != shim.OK {
+		n.t.Fatalf("Init of %s failed: %s", name, response.Message)
+	}
+	return s
+}
+
+// advance - Moves the clock of the network on
+func (n *testNetwork) advance(d time.Duration) {
+	n.now = n.now.Add(d)
+}
+
+// submit - Runs a transaction proposed to a chaincode and commits the writes of every chaincode it called when it
+// succeeds
+func (n *testNetwork) submit(s *testStub, caller *testCaller, args []string, init bool) pb.Response {
+	n.txSeq = n.txSeq + 1
+	n.now = n.now.Add(time.Second)
+	tx := &testTx{id: fmt.Sprintf("tx%05d", n.txSeq), time: n.now, caller: caller, proposal: testProposal(s.Name)}
+	n.lastTx = tx
+
+	response := s.run(tx, args, init)
+	if response.Status < shim.ERRORTHRESHOLD {
+		for _, stub := range n.stubs {
+			if stub.tx == tx {
+				stub.commit()
+			}
+		}
+	}
+	return response
+}
+
+// invoke - Submits a transaction calling a function of the chaincode
+func (s *testStub) invoke(caller *testCaller, args ...string) pb.Response {
+	return s.network.submit(s, caller, args, false)
+}
+
+// mustInvoke - Submits a transaction and fails the test unless it succeeds, returns the payload
+func (s *testStub) mustInvoke(caller *testCaller, args ...string) []byte {
+	s.network.t.Helper()
+	response := s.invoke(caller, args...)
+	if response.Status != shim.OK {
+		s.network.t.Fatalf("%s failed: %d %s", args[0], response.Status, response.Message)
+	}
+	return response.Payload
+}
+
+// mustFail - Submits a transaction and fails the test if it succeeds, returns the error message
+func (s *testStub) mustFail(caller *testCaller, args ...string) string {
+	s.network.t.Helper()
+	response := s.invoke(caller, args...)
+	if response.Status == shim.OK {
+		s.network.t.Fatalf("%s succeeded, expected it to fail: %s", args[0], response.Payload)
+	}
+	return response.Message
+}
+
+// run - Runs the chaincode for a transaction, within the writes it already made when it is called again by it
+func (s *testStub) run(tx *testTx, args []string, init bool) pb.Response {
+	if s.tx != tx {
+		s.tx = tx
+		s.writes = map[string][]byte{}
+		s.event = nil
+	}
+	s.args = [][]byte{}
+	for _, arg := range args {
+		s.args = append(s.args, []byte(arg))
+	}
+
+	s.MockTransactionStart(tx.id)
+	defer s.MockTransactionEnd(tx.id)
+	if init {
+		return s.cc.Init(s)
+	}
+	return s.cc.Invoke(s)
+}
+
+// commit - Writes the state changes of the last transaction to the ledger
+func (s *testStub) commit() {
+	s.MockTransactionStart(s.tx.id)
+	defer s.MockTransactionEnd(s.tx.id)
+
+	txTimestamp := &timestamp.Timestamp{Seconds: s.tx.time.Unix(), Nanos: int32(s.tx.time.Nanosecond())}
+	for key, value := range s.writes {
+		if value == nil {
+			s.MockStub.DelState(key)
+		} else {
+			s.MockStub.PutState(key, value)
+		}
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.tx.id, Value: value, Timestamp: txTimestamp, IsDelete: value == nil})
+	}
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := []string{}
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.tx.caller.serializedIdentity(s.network.t), nil
+}
+
+func (s *testStub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return s.tx.proposal, nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.tx.time.Unix(), Nanos: int32(s.tx.time.Nanosecond())}, nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	s.writes[key] = value
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.event = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// InvokeChaincode - Runs a chaincode of the network within the transaction in progress
+func (s *testStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	other, found := s.network.stubs[chaincodeName]
+	if !found || (channel != "" && channel != testChannel) {
+		return shim.Error("chaincode " + chaincodeName + " is not deployed on channel " + channel)
+	}
+	stringArgs := []string{}
+	for _, arg := range args {
+		stringArgs = append(stringArgs, string(arg))
+	}
+	return other.run(s.tx, stringArgs, false)
+}
+
+// GetStateByPartialCompositeKeyWithPagination - Pages of a partial composite key query, the bookmark being the key
+// the next page starts at
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	resultsIterator, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &testIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		} else if len(page.kvs) == int(pageSize) {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+	return page, metadata, nil
+}
+
+// GetQueryResult - Records the rich query, which only CouchDB can run, and finds nothing
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	s.queries = append(s.queries, query)
+	return &testIterator{}, nil
+}
+
+// GetQueryResultWithPagination - Records the rich query, which only CouchDB can run, and finds nothing
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	s.queries = append(s.queries, query)
+	return &testIterator{}, &pb.QueryResponseMetadata{}, nil
+}
+
+// GetHistoryForKey - Committed versions of a key, oldest first
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &testHistoryIterator{modifications: s.history[key]}, nil
+}
+
+// testIterator - Iterator over a fixed list of query results
+type testIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (i *testIterator) HasNext() bool {
+	return len(i.kvs) > 0
+}
+
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	kv := i.kvs[0]
+	i.kvs = i.kvs[1:]
+	return kv, nil
+}
+
+func (i *testIterator) Close() error {
+	return nil
+}
+
+// testHistoryIterator - Iterator over the versions of a key
+type testHistoryIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (i *testHistoryIterator) HasNext() bool {
+	return len(i.modifications) > 0
+}
+
+func (i *testHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	modification := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return modification, nil
+}
+
+func (i *testHistoryIterator) Close() error {
+	return nil
+}
+
+// testProposal - Signed proposal of a transaction sent to the named chaincode
+func testProposal(chaincodeName string) *pb.SignedProposal {
+	extension, _ := proto.Marshal(&pb.ChaincodeHeaderExtension{ChaincodeId: &pb.ChaincodeID{Name: chaincodeName}})
+	channelHeader, _ := proto.Marshal(&common.ChannelHeader{ChannelId: testChannel, Extension: extension})
+	header, _ := proto.Marshal(&common.Header{ChannelHeader: channelHeader})
+	proposal, _ := proto.Marshal(&pb.Proposal{Header: header})
+	return &pb.SignedProposal{ProposalBytes: proposal}
+}
+
+// newTestCaller - Identity of an MSP with the given certificate attributes, see CertificateAttributes of Fabric CA
+func newTestCaller(mspId string, name string, attrs map[string]string) *testCaller {
+	return &testCaller{mspId: mspId, name: name, attrs: attrs}
+}
+
+// serializedIdentity - Creator of the transactions of the caller, a self-signed certificate carrying its attributes
+func (c *testCaller) serializedIdentity(t *testing.T) []byte {
+	if c.creator != nil {
+		return c.creator
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrsAsBytes, err := json.Marshal(map[string]map[string]string{"attrs": c.attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: c.name, Organization: []string{c.mspId}},
+		NotBefore:       testStartTime.Add(-time.Hour),
+		NotAfter:        testStartTime.Add(365 * 24 * time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrsAsBytes}}}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: c.mspId, IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.creator = creator
+	return creator
+}
+
+// unmarshal - Decodes the JSON payload of a response, failing the test when it does not decode
+func unmarshal(t *testing.T, payload []byte, v interface{}) {
+	t.Helper()
+	err := json.Unmarshal(payload, v)
+	if err != nil {
+		t.Fatalf("cannot decode %s: %s", payload, err)
+	}
+}
@@ -118,7 +118,7 @@ http://localhost:4000/channels/mychannel/chaincodes \
         \"chaincodeName\":\"cc_movies\",
         \"chaincodeVersion\":\"v0\",
         \"chaincodeType\": \"$LANGUAGE\",
-        \"args\":[]
+        \"args\":[\"Org1MSP\"]
 }"
 echo
 echo
@@ -160,7 +160,7 @@ http://localhost:4000/channels/mychannel/chaincodes \
         \"chaincodeName\":\"cc_bookings\",
         \"chaincodeVersion\":\"v0\",
         \"chaincodeType\": \"$LANGUAGE\",
-        \"args\":[]
+        \"args\":[\"cc_movies\", \"mychannel\", \"Org1MSP\"]
 }"
 echo
 echo
@@ -18,6 +18,14 @@ LANGUAGE="golang"
 CC_BOOKING_SRC_PATH="github.com/chaincode/bookings"
 CC_MOVIES_SRC_PATH="github.com/chaincode/movies"
 
+# The shows are created for tomorrow, a Show ID is the Movie ID of the catalog and the start time joined by an underscore
+SHOW_DATE=$(date -u -d tomorrow +%Y-%m-%d 2>/dev/null || date -u -v+1d +%Y-%m-%d)
+INCEPTION_START="${SHOW_DATE}T09:00:00+05:30"
+SHAWSHANK_START="${SHOW_DATE}T18:00:00+05:30"
+INCEPTION_SHOW="inception-2010_${INCEPTION_START}"
+SHAWSHANK_SHOW="the-shawshank-redemption-1994_${SHAWSHANK_START}"
+GODFATHER_SHOW="the-godfather-1972_${INCEPTION_START}"
+
 echo
 echo "POST request Enroll on Org1  ..."
 echo
@@ -63,7 +71,7 @@ echo
 echo "Transaction ID is $TRX_ID"
 echo
 echo
-echo " --- INVOKE MOVIE CHAINCODE - ORG1 --- "
+echo " --- INVOKE MOVIE CHAINCODE - CREATE SCREEN LAYOUT --- "
 TRX_ID=$(
     curl -s -X POST \
     http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
@@ -71,38 +79,95 @@ TRX_ID=$(
     -H "content-type: application/json" \
     -d '{
             "peers": ["peer0.org1.example.com","peer1.org1.example.com"],
-            "fcn":"initMovieDetails",
-            "args":["Inception", "09am - 12pm", "100", "100", "False"]
+            "fcn":"initScreen",
+            "args":["SCREEN-3", "Audi 3", "[{\"rowLabel\":\"A\",\"seatsPerRow\":6,\"category\":\"Premium\",\"aisles\":[3]},{\"rowLabel\":\"B\",\"seatsPerRow\":6,\"category\":\"Recliner\",\"blocked\":[1,6]}]"]
 }'
 )
 echo "Transaction ID is $TRX_ID"
 echo
+echo " --- INVOKE MOVIE CHAINCODE - REGISTER MOVIE IN THE CATALOG --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"registerMovie\",
+            \"args\":[\"inception-2010\", \"Inception\", \"148\", \"Sci-Fi\", \"English\", \"UA\", \"2010-07-16\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo " --- INVOKE MOVIE CHAINCODE - REGISTER MOVIE IN THE CATALOG --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"registerMovie\",
+            \"args\":[\"the-shawshank-redemption-1994\", \"The Shawshank Redemption\", \"142\", \"Drama\", \"English\", \"A\", \"1994-09-23\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
 echo " --- INVOKE MOVIE CHAINCODE - ORG1 --- "
 TRX_ID=$(
     curl -s -X POST \
     http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
     -H "authorization: Bearer $ORG1_TOKEN" \
     -H "content-type: application/json" \
-    -d '{
-            "peers": ["peer0.org1.example.com","peer1.org1.example.com"],
-            "fcn":"initMovieDetails",
-            "args":["The Shawshank Redemption", "6pm-9pm", "100", "10", "False"]
-}'
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"initMovieDetails\",
+            \"args\":[\"inception-2010\", \"$INCEPTION_START\", \"SCREEN-1\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo " --- INVOKE MOVIE CHAINCODE - ORG1 --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"initMovieDetails\",
+            \"args\":[\"the-shawshank-redemption-1994\", \"$SHAWSHANK_START\", \"SCREEN-3\"]
+}"
 )
 echo "Transaction ID is $TRX_ID"
 echo
 echo
-echo " --- INVOKE MOVIE CHAINCODE - ORG2 --- "
+echo " --- INVOKE MOVIE CHAINCODE - ORG1 (Org1MSP is the theater admin MSP, The Godfather is in the catalog from the dummy entries) --- "
 TRX_ID=$(
     curl -s -X POST \
     http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
-    -H "authorization: Bearer $ORG2_TOKEN" \
+    -H "authorization: Bearer $ORG1_TOKEN" \
     -H "content-type: application/json" \
-    -d '{
-            "peers": ["peer0.org2.example.com","peer1.org2.example.com"],
-            "fcn":"initMovieDetails",
-            "args":["The Godfather", "09am - 12pm", "100", "0", "True"]
-}'
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"initMovieDetails\",
+            \"args\":[\"the-godfather-1972\", \"$INCEPTION_START\", \"SCREEN-2\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- INVOKE MOVIE CHAINCODE - SET SHOW PRICING --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"setShowPricing\",
+            \"args\":[\"$INCEPTION_SHOW\", \"INR\", \"20000\", \"{\\\"Premium\\\":30000,\\\"Recliner\\\":50000}\"]
+}"
 )
 echo "Transaction ID is $TRX_ID"
 echo
@@ -113,11 +178,73 @@ TRX_ID=$(
     http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
     -H "authorization: Bearer $ORG1_TOKEN" \
     -H "content-type: application/json" \
-    -d '{
-                "peers": ["peer0.org1.example.com","peer1.org1.example.com"],
-                "fcn":"initBookingDetails",
-                "args":["Steve Smith", "Inception", "09am - 12pm", "6"]
-}'
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"initBookingDetails\",
+                \"args\":[\"Jim\", \"$INCEPTION_SHOW\", \"6\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- INVOKE BOOKING CHAINCODE - Book Selected Seats --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"initBookingWithSeats\",
+                \"args\":[\"Jim\", \"$INCEPTION_SHOW\", \"F4\", \"F5\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- INVOKE BOOKING CHAINCODE - Hold Seats for Checkout --- "
+HOLD=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"holdSeats\",
+                \"args\":[\"Jim\", \"$INCEPTION_SHOW\", \"2\", \"J5\", \"J6\"]
+}"
+)
+echo "Hold is $HOLD"
+HOLD_ID=${HOLD##* }
+echo
+echo
+echo " --- INVOKE BOOKING CHAINCODE - Confirm the Hold into a Booking --- "
+BOOKING=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"confirmHold\",
+                \"args\":[\"$HOLD_ID\"]
+}"
+)
+echo "Booking is $BOOKING"
+BOOKING_ID=${BOOKING##* }
+echo
+echo
+echo " --- INVOKE BOOKING CHAINCODE - Cancel the Booking confirmed from the Hold --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"cancelBooking\",
+                \"args\":[\"$BOOKING_ID\"]
+}"
 )
 echo "Transaction ID is $TRX_ID"
 echo
@@ -128,15 +255,45 @@ TRX_ID=$(
     http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
     -H "authorization: Bearer $ORG1_TOKEN" \
     -H "content-type: application/json" \
-    -d '{
-                "peers": ["peer0.org1.example.com","peer1.org1.example.com"],
-                "fcn":"initBookingDetails",
-                "args":["Rahul Dravid", "The Shawshank Redemption", "6pm-9pm", "11"]
-}'
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"initBookingDetails\",
+                \"args\":[\"Jim\", \"$SHAWSHANK_SHOW\", \"11\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo
+echo " --- INVOKE BOOKING CHAINCODE - Join the Waitlist when seats are not available --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"joinWaitlist\",
+                \"args\":[\"Jim\", \"$SHAWSHANK_SHOW\", \"11\"]
+}"
 )
 echo "Transaction ID is $TRX_ID"
 echo
 echo
+echo " --- INVOKE BOOKING CHAINCODE - Offer the free seats of a show to its Waitlist --- "
+PROMOTIONS=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+                \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+                \"fcn\":\"promoteWaitlist\",
+                \"args\":[\"$SHAWSHANK_SHOW\"]
+}"
+)
+echo "Promotions are $PROMOTIONS"
+echo
 echo
 echo " --- INVOKE BOOKING CHAINCODE - Book Another Ticket for Another movie --- "
 TRX_ID=$(
@@ -144,10 +301,76 @@ TRX_ID=$(
     http://localhost:4000/channels/mychannel/chaincodes/cc_bookings \
     -H "authorization: Bearer $ORG2_TOKEN" \
     -H "content-type: application/json" \
-    -d '{
-            "peers": ["peer0.org2.example.com","peer1.org2.example.com"],
-            "fcn":"initBookingDetails",
-            "args":["David Warner", "The Shawshank Redemption", "6pm-9pm", "9"]
-}'
+    -d "{
+            \"peers\": [\"peer0.org2.example.com\",\"peer1.org2.example.com\"],
+            \"fcn\":\"initBookingDetails\",
+            \"args\":[\"Barry\", \"$SHAWSHANK_SHOW\", \"9\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- INVOKE MOVIE CHAINCODE - Reschedule a show an hour later and cut its capacity to 80 seats --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"updateShow\",
+            \"args\":[\"$INCEPTION_SHOW\", \"${SHOW_DATE}T10:00:00+05:30\", \"\", \"80\"]
+}"
 )
 echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- INVOKE MOVIE CHAINCODE - Cancel a show, its bookings become due a refund --- "
+TRX_ID=$(
+    curl -s -X POST \
+    http://localhost:4000/channels/mychannel/chaincodes/cc_movies \
+    -H "authorization: Bearer $ORG1_TOKEN" \
+    -H "content-type: application/json" \
+    -d "{
+            \"peers\": [\"peer0.org1.example.com\",\"peer1.org1.example.com\"],
+            \"fcn\":\"cancelShow\",
+            \"args\":[\"$GODFATHER_SHOW\", \"Projector failure\"]
+}"
+)
+echo "Transaction ID is $TRX_ID"
+echo
+echo
+echo " --- QUERY MOVIE CHAINCODE - Search the catalog by the start of a title, case and spacing do not matter --- "
+curl -s -X GET \
+  "http://localhost:4000/channels/mychannel/chaincodes/cc_movies?peer=peer0.org1.example.com&fcn=searchMovies&args=%5B%22the%20%20G%22%5D" \
+  -H "authorization: Bearer $ORG1_TOKEN" \
+  -H "content-type: application/json"
+echo
+echo
+echo " --- QUERY MOVIE CHAINCODE - Look up a movie by its title --- "
+curl -s -X GET \
+  "http://localhost:4000/channels/mychannel/chaincodes/cc_movies?peer=peer0.org1.example.com&fcn=getMovie&args=%5B%22the%20grudge%22%5D" \
+  -H "authorization: Bearer $ORG1_TOKEN" \
+  -H "content-type: application/json"
+echo
+echo
+echo " --- QUERY MOVIE CHAINCODE - List the first page of shows that are not house full --- "
+curl -s -X GET \
+  "http://localhost:4000/channels/mychannel/chaincodes/cc_movies?peer=peer0.org1.example.com&fcn=listShows&args=%5B%2210%22%2C%22%22%2C%22True%22%5D" \
+  -H "authorization: Bearer $ORG1_TOKEN" \
+  -H "content-type: application/json"
+echo
+echo
+echo " --- QUERY MOVIE CHAINCODE - Rich query for the scheduled shows of a movie --- "
+curl -s -X GET \
+  "http://localhost:4000/channels/mychannel/chaincodes/cc_movies?peer=peer0.org1.example.com&fcn=queryShows&args=%5B%22%7B%5C%22movieId%5C%22%3A%5C%22inception-2010%5C%22%2C%5C%22showStatus%5C%22%3A%5C%22Scheduled%5C%22%7D%22%2C%2210%22%2C%22%22%5D" \
+  -H "authorization: Bearer $ORG1_TOKEN" \
+  -H "content-type: application/json"
+echo
+echo
+echo " --- QUERY BOOKING CHAINCODE - Rich query for the caller's bookings of a movie --- "
+curl -s -X GET \
+  "http://localhost:4000/channels/mychannel/chaincodes/cc_bookings?peer=peer0.org1.example.com&fcn=queryBookings&args=%5B%22%7B%5C%22movieName%5C%22%3A%5C%22Inception%5C%22%7D%22%2C%2210%22%2C%22%22%5D" \
+  -H "authorization: Bearer $ORG1_TOKEN" \
+  -H "content-type: application/json"
+echo
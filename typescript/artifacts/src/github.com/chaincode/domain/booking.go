@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Composite key index over Owner ID and Booking ID, used to find every booking of a customer
+var UserBookingIndex = "indexUserBooking"
+
+// docType of the bookings, which rich queries select them by
+var BookingDocType = "booking"
+
+// Booking - A booking of a customer, stored under its Booking ID. OwnerId identifies the identity that manages the
+// booking as MSP ID/enrollment ID, BookedByUser is the customer's name, the enrollment ID unless box-office staff
+// booked for a walk-in customer. ShowId is the show booked, MovieName and TimeSlot repeat its parts for display and
+// queries. BookingStatus is Booked, Cancelled, or RefundDue when the show was cancelled by the theater.
+type Booking struct {
+	DocType          string        `json:"docType"`
+	BookedByUser     string        `json:"bookedByUser"`
+	OwnerId          string        `json:"ownerId"`
+	ShowId           string        `json:"showId"`
+	MovieName        string        `json:"movieName"`
+	TimeSlot         string        `json:"timeSlot"`
+	ReqNmbrOfTickets int           `json:"reqNmbrOfTickets"`
+	BookingId        string        `json:"bookingId"`
+	SeatDetails      []SeatDetails `json:"seatDetails"`
+	BookingTime      string        `json:"bookingTime"`
+	BookingStatus    string        `json:"bookingStatus"`
+	TotalPrice       int           `json:"totalPrice"`
+	Currency         string        `json:"currency"`
+	TheaterId        string        `json:"theaterId"`
+}
+
+// SeatDetails - A booked seat with its Receipt Number, price and Water to Soda exchange. The flags are True or False,
+// an exchange flagged True is redeemable as far as the theater's daily quota covers it in booking order.
+type SeatDetails struct {
+	SeatNumber              string `json:"seatNumber"`
+	ReceiptNumber           string `json:"receiptNumber"`
+	BeverageFlag            string `json:"beverageFlag"`
+	WaterToSodaExchangeFlag string `json:"waterToSodaExchangeFlag"`
+	Category                string `json:"category"`
+	Price                   int    `json:"price"`
+	BeverageRedeemedFlag    string `json:"beverageRedeemedFlag"`
+	RedemptionTime          string `json:"redemptionTime"`
+}
+
+// UserBookingKey - Key of a booking in the index of the bookings of its owner
+func UserBookingKey(stub shim.ChaincodeStubInterface, ownerId string, bookingId string) (string, error) {
+	return stub.CreateCompositeKey(UserBookingIndex, []string{ownerId, bookingId})
+}
+
+// UnmarshalBooking - Booking from its JSON
+func UnmarshalBooking(bookingAsBytes []byte) (*Booking, error) {
+	var booking Booking
+	err := json.Unmarshal(bookingAsBytes, &booking)
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Error codes of the error envelope, clients branch on these rather than on the messages. Errors of a called
+// chaincode keep their code, UPSTREAM_FAILURE is for calls that failed without one.
+var CodeNotFound = "NOT_FOUND"
+var CodeSoldOut = "SOLD_OUT"
+var CodeInsufficientSeats = "INSUFFICIENT_SEATS"
+var CodeInvalidArgument = "INVALID_ARGUMENT"
+var CodeConflict = "CONFLICT"
+var CodeUnauthorized = "UNAUTHORIZED"
+var CodeUpstreamFailure = "UPSTREAM_FAILURE"
+var CodeInternal = "INTERNAL"
+
+// ChaincodeError - Error envelope of the failed calls, returned as the message of the error response. Code is one of
+// the error codes and Message the explanation for people.
+type ChaincodeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error - Message of the error, so that a ChaincodeError can be returned as an error
+func (e *ChaincodeError) Error() string {
+	return e.Message
+}
+
+// NewError - ChaincodeError with an error code and a formatted message
+func NewError(code string, format string, a ...interface{}) *ChaincodeError {
+	return &ChaincodeError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// UpstreamError - ChaincodeError of a failed call to another chaincode. Its error envelope is passed on as it is,
+// anything else the call failed with is an UPSTREAM_FAILURE.
+func UpstreamError(chaincodeName string, response pb.Response) *ChaincodeError {
+	var envelope ChaincodeError
+	err := json.Unmarshal([]byte(response.Message), &envelope)
+	if err != nil || envelope.Code == "" {
+		return NewError(CodeUpstreamFailure, "%s failed with status %d: %s", chaincodeName, response.Status, response.Message)
+	}
+	return &envelope
+}
+
+// Failed - Error response for an error, keeping the error code of a ChaincodeError. Any other error comes from the
+// ledger or from encoding and is reported as INTERNAL.
+func Failed(err error) pb.Response {
+	if chaincodeErr, ok := err.(*ChaincodeError); ok {
+		return ErrorResponse(chaincodeErr.Code, chaincodeErr.Message)
+	}
+	return ErrorResponse(CodeInternal, err.Error())
+}
+
+// ErrorResponse - Error response carrying the error envelope {"code", "message"} as its message. UNAUTHORIZED
+// responses have status 403, the others the status of shim.Error.
+func ErrorResponse(code string, message string) pb.Response {
+	envelopeAsBytes, err := json.Marshal(ChaincodeError{Code: code, Message: message})
+	if err != nil {
+		return shim.Error(message)
+	}
+	response := shim.Error(string(envelopeAsBytes))
+	if code == CodeUnauthorized {
+		response.Status = 403
+	}
+	return response
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RoleAttribute - Certificate attribute carrying the role of an identity
+var RoleAttribute = "role"
+
+// Roles of the staff. Box-office staff book for walk-in customers and may manage every booking, theater admins
+// can do the same and also run the shows, screens and configuration.
+var BoxOfficeRole = "boxOffice"
+var TheaterAdminRole = "theaterAdmin"
+
+// CallerRole - Role of the identity submitting the transaction. Every identity of the theater admin MSP is a theater
+// admin, the others have the role of their certificate's role attribute, if any.
+func CallerRole(stub shim.ChaincodeStubInterface, theaterAdminMSP string) (string, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the MSP ID of the caller: %s", err)
+	}
+	if theaterAdminMSP != "" && mspId == theaterAdminMSP {
+		return TheaterAdminRole, nil
+	}
+
+	role, _, err := cid.GetAttributeValue(stub, RoleAttribute)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the role of the caller: %s", err)
+	}
+	return role, nil
+}
+
+// CheckStaff - Errors unless the caller has one of the given roles, see CallerRole. Both chaincodes check their
+// staff with it, so the same identities are staff in either.
+func CheckStaff(stub shim.ChaincodeStubInterface, theaterAdminMSP string, roles ...string) error {
+	role, err := CallerRole(stub, theaterAdminMSP)
+	if err != nil {
+		return err
+	}
+	for _, allowedRole := range roles {
+		if role == allowedRole {
+			return nil
+		}
+	}
+
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("Caller from %s does not have the %s role", mspId, strings.Join(roles, " or "))
+}
+
+// InvokedThrough - Whether the transaction proposal was sent to the named chaincode. A chaincode called by another
+// one runs under the proposal of the calling chaincode, with the same caller's identity either way.
+func InvokedThrough(stub shim.ChaincodeStubInterface, chaincodeName string) bool {
+	signedProposal, err := stub.GetSignedProposal()
+	if err != nil || signedProposal == nil {
+		return false
+	}
+
+	proposal := &pb.Proposal{}
+	if proto.Unmarshal(signedProposal.ProposalBytes, proposal) != nil {
+		return false
+	}
+	header := &common.Header{}
+	if proto.Unmarshal(proposal.Header, header) != nil {
+		return false
+	}
+	channelHeader := &common.ChannelHeader{}
+	if proto.Unmarshal(header.ChannelHeader, channelHeader) != nil {
+		return false
+	}
+	extension := &pb.ChaincodeHeaderExtension{}
+	if proto.Unmarshal(channelHeader.Extension, extension) != nil {
+		return false
+	}
+
+	return extension.ChaincodeId != nil && extension.ChaincodeId.Name == chaincodeName
+}
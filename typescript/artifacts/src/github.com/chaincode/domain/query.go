@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of the fields a query selector can filter on. String fields match exactly ($eq, $in), number fields by
+// value ($eq, $gt, $gte, $lt, $lte) and time fields, RFC 3339 times to the second, by range ($gt, $gte, $lt, $lte).
+var StringField = "string"
+var NumberField = "number"
+var TimeField = "time"
+
+var selectorOperators = map[string]map[string]bool{
+	StringField: {"$eq": true, "$in": true},
+	NumberField: {"$eq": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true},
+	TimeField:   {"$gt": true, "$gte": true, "$lt": true, "$lte": true},
+}
+
+// Selector - A validated Mango selector, field name to value or to operator and value
+type Selector map[string]interface{}
+
+// ParseSelector - Parses a Mango selector and checks it only filters the given fields, by their kind, with plain
+// values or the operators of the kind. Anything else, $and, $or, $regex or unknown fields, is an INVALID_ARGUMENT.
+// Times are turned into UTC RFC 3339 times without a fraction, the way they are stored, so that they compare in
+// order as strings. An empty selector selects everything.
+func ParseSelector(selectorJSON string, fields map[string]string) (Selector, error) {
+	selector := Selector{}
+	if strings.TrimSpace(selectorJSON) == "" {
+		return selector, nil
+	}
+
+	var raw map[string]interface{}
+	err := json.Unmarshal([]byte(selectorJSON), &raw)
+	if err != nil {
+		return nil, NewError(CodeInvalidArgument, "Expecting the selector as a JSON object: %s", err.Error())
+	}
+
+	for field, condition := range raw {
+		kind, found := fields[field]
+		if !found {
+			return nil, NewError(CodeInvalidArgument, "Cannot select on %s, expecting one of %s", field, strings.Join(fieldNames(fields), ", "))
+		}
+
+		operators, isObject := condition.(map[string]interface{})
+		if !isObject {
+			if kind == TimeField {
+				return nil, NewError(CodeInvalidArgument, "Expecting a range for %s", field)
+			}
+			value, err := selectorValue(field, kind, condition)
+			if err != nil {
+				return nil, err
+			}
+			selector[field] = value
+			continue
+		}
+
+		if len(operators) == 0 {
+			return nil, NewError(CodeInvalidArgument, "Expecting an operator for %s", field)
+		}
+		checkedOperators := map[string]interface{}{}
+		for operator, operand := range operators {
+			if !selectorOperators[kind][operator] {
+				return nil, NewError(CodeInvalidArgument, "Operator %s cannot be used on %s", operator, field)
+			}
+			if operator == "$in" {
+				values, isArray := operand.([]interface{})
+				if !isArray || len(values) == 0 {
+					return nil, NewError(CodeInvalidArgument, "Expecting a list of values for $in on %s", field)
+				}
+				checkedValues := []interface{}{}
+				for _, value := range values {
+					checkedValue, err := selectorValue(field, kind, value)
+					if err != nil {
+						return nil, err
+					}
+					checkedValues = append(checkedValues, checkedValue)
+				}
+				checkedOperators[operator] = checkedValues
+				continue
+			}
+			checkedValue, err := selectorValue(field, kind, operand)
+			if err != nil {
+				return nil, err
+			}
+			checkedOperators[operator] = checkedValue
+		}
+		selector[field] = checkedOperators
+	}
+	return selector, nil
+}
+
+// Query - CouchDB query of the documents of a docType matching the selector
+func (selector Selector) Query(docType string) (string, error) {
+	querySelector := map[string]interface{}{"docType": docType}
+	for field, condition := range selector {
+		querySelector[field] = condition
+	}
+	queryAsBytes, err := json.Marshal(map[string]interface{}{"selector": querySelector})
+	if err != nil {
+		return "", err
+	}
+	return string(queryAsBytes), nil
+}
+
+// selectorValue - A value of the selector checked against the kind of its field
+func selectorValue(field string, kind string, value interface{}) (interface{}, error) {
+	switch kind {
+	case StringField:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+		return nil, NewError(CodeInvalidArgument, "Expecting a string value for %s", field)
+	case NumberField:
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+		return nil, NewError(CodeInvalidArgument, "Expecting a number value for %s", field)
+	default:
+		text, _ := value.(string)
+		selectedTime, err := time.Parse(time.RFC3339, text)
+		if err != nil || selectedTime.Nanosecond() != 0 {
+			return nil, NewError(CodeInvalidArgument, "Expecting an RFC 3339 time to the second for %s", field)
+		}
+		return selectedTime.UTC().Format(time.RFC3339), nil
+	}
+}
+
+// fieldNames - Names of the selectable fields in alphabetical order, for the error messages
+func fieldNames(fields map[string]string) []string {
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -3,26 +3,141 @@ package main
 import (
     "encoding/json"
     "fmt"
+    "net/url"
+    "regexp"
     "time"
     "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
 
+    "github.com/chaincode/domain"
+    "github.com/hyperledger/fabric/common/util"
     "github.com/hyperledger/fabric/core/chaincode/shim"
     pb "github.com/hyperledger/fabric/protos/peer"
+    "golang.org/x/text/unicode/norm"
 )
 var logger = shim.NewLogger("Movie-Chaincode to Store Movies")
 
+// Composite key object type of the screen layouts, one key per Screen ID
+var screenObject = "screen"
+
+// Composite key object type of the ticket count changes of the shows, one key per show and Transaction ID. Bookings
+// write these instead of the show record, which only takes them in when compactShowTickets runs.
+var showTicketDeltaObject = "showTicketDelta"
+
+// Composite key index over Screen ID, day and Show ID of the shows with a start time, used to find the shows
+// sharing a Screen on a day
+var screenDayIndex = "indexScreenDay"
+
+// Index of the catalog over normalized title and Movie ID, searched by title prefix. Unlike the composite key indexes
+// its keys are simple keys, a prefix search needs a range scan and composite keys cannot be range scanned.
+var movieTitleIndex = "indexMovieTitle"
+
+// Day of a show in the indexScreenDay index, the UTC date of its start time
+var scheduleDayFormat = "2006-01-02"
+
+// Key of the chaincode configuration written by Init
+var movieConfigKey = "movieChaincodeConfig"
+
+// Minutes a Screen is kept free after a show for cleaning until setCleaningBuffer changes it
+var defaultCleaningBufferMinutes = 15
+
+// Functions creating, changing or seeding movies, shows and screens, only theater admins can call them
+var theaterAdminFunctions = map[string]bool {
+    "registerMovie": true,
+    "updateMovie": true,
+    "initMovieDetails": true,
+    "updateShow": true,
+    "cancelShow": true,
+    "createDummyEntries": true,
+    "setShowPricing": true,
+    "setCleaningBuffer": true,
+    "compactShowTickets": true,
+    "initScreen": true }
+
+// Functions changing the seat inventory, called by the Bookings chaincode for its bookings and holds.
+// Called directly they are limited to theater admins.
+var seatInventoryFunctions = map[string]bool {
+    "reserveShowSeats": true,
+    "releaseShowSeats": true,
+    "holdShowSeats": true,
+    "confirmHeldSeats": true,
+    "releaseHeldSeats": true }
+
+// Largest page of shows listShows and queryShows return
+var maxShowPageSize = 100
+
+// Fields of the shows queryShows can filter on, and their kinds. The META-INF CouchDB indexes cover these queries.
+var showQueryFields = map[string]string {
+    "showId": domain.StringField,
+    "movieId": domain.StringField,
+    "movieName": domain.StringField,
+    "availalbeTimeSlots": domain.StringField,
+    "startTime": domain.TimeField,
+    "endTime": domain.TimeField,
+    "runtimeMinutes": domain.NumberField,
+    "theaterId": domain.StringField,
+    "screenId": domain.StringField,
+    "showStatus": domain.StringField,
+    "modificationTime": domain.TimeField }
+
+// Movie IDs of the catalog are lowercase slugs such as inception-2010
+var movieIdPattern = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// Longest runtime a show can be created with, in minutes
+var maxRuntimeMinutes = 600
+
+// Theater of the screens created without one, and of the shows created before screens had a theater
+var defaultTheaterId = "DEFAULT"
+
 // MovieChaincode is the definition of the chaincode structure.
 type MovieChaincode struct {}
 
-type MovieDetails struct {
-    MovieName string `json:"movieName"`
-    AvailalbeTimeSlots string `json:"availalbeTimeSlots"`
-    TotalTickets int `json:"totalTickets"`
-    RemainingTickets int `json:"remainingTickets"`
-    HouseFullFlag string `json:"houseFullFlag"`
+// ShowPage - A page of listShows or queryShows. Bookmark is passed back to fetch the next page. After the last page of
+// listShows it is empty, queryShows is at its last page when fewer records were fetched than the page size.
+// FetchedRecordsCount counts the shows read for the page including any that were left out.
+type ShowPage struct {
+    Shows []domain.Show `json:"shows"`
+    Bookmark string `json:"bookmark"`
+    FetchedRecordsCount int `json:"fetchedRecordsCount"`
+}
+
+// MovieConfig - Theater admin MSP, Bookings chaincode and the minutes a Screen stays free between two shows
+type MovieConfig struct {
+    TheaterAdminMSP string `json:"theaterAdminMSP"`
+    BookingsChaincode string `json:"bookingsChaincode"`
+    CleaningBufferMinutes int `json:"cleaningBufferMinutes"`
+}
+
+// ShowTicketDelta - Change of the Remaining Tickets of a show made by one transaction
+type ShowTicketDelta struct {
+    MovieId string `json:"movieId"`
+    TimeSlot string `json:"timeSlot"`
+    DeltaId string `json:"deltaId"`
+    Change int `json:"change"`
+}
+
+// Screen - Physical seat layout of a screen, the capacity and seat inventory of its shows are generated from it
+type Screen struct {
+    ScreenId string `json:"screenId"`
+    ScreenName string `json:"screenName"`
+    TheaterId string `json:"theaterId"`
+    Rows []ScreenRow `json:"rows"`
+    TotalSeats int `json:"totalSeats"`
     ModificationTime time.Time `json:"modificationTime"`
 }
 
+// ScreenRow - A row of the screen. Seats are numbered RowLabel + position (A1, A2 ...), Aisles lists the
+// positions followed by an aisle and Blocked the positions that are never sold.
+type ScreenRow struct {
+    RowLabel string `json:"rowLabel"`
+    SeatsPerRow int `json:"seatsPerRow"`
+    Category string `json:"category"`
+    Aisles []int `json:"aisles"`
+    Blocked []int `json:"blocked"`
+}
+
 // --- Calling MAIN ---
 func main() {
     err := shim.Start(new(MovieChaincode))
@@ -31,8 +146,32 @@ func main() {
     }
 }
 
-// Init initializes chaincode
+// Init - Initializes the chaincode, optional args are the theater admin MSP ID and the Bookings chaincode name
 func(t * MovieChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+
+    // An upgrade without args keeps the configuration it finds
+    _, args := stub.GetFunctionAndParameters()
+    if len(args) == 0 {
+        return shim.Success(nil)
+    } else if len(args) > 2 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Theater admin MSP ID and optionally the Bookings chaincode name")
+    }
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    config.TheaterAdminMSP = args[0]
+    config.BookingsChaincode = "cc_bookings"
+    if len(args) == 2 && args[1] != "" {
+        config.BookingsChaincode = args[1]
+    }
+
+    err = putMovieConfig(stub, config)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
     return shim.Success(nil)
 }
 
@@ -40,98 +179,2345 @@ func(t * MovieChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 func(t * MovieChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
     function, args := stub.GetFunctionAndParameters()
     fmt.Println("invoke is running " + function)
+
+    // Checking the caller, the read functions stay open to everyone
+    if theaterAdminFunctions[function] || (seatInventoryFunctions[function] && !invokedThroughBookings(stub)) {
+        err := checkTheaterAdmin(stub)
+        if err != nil {
+            return unauthorized(function, err)
+        }
+    }
+
     // Handle different functions
-    if function == "initMovieDetails" { //creates a new entry for Movie
+    if function == "registerMovie" { // Add a Movie to the catalog
+        return t.registerMovie(stub, args)
+    } else if function == "updateMovie" { // Correct the catalog details of a Movie
+        return t.updateMovie(stub, args)
+    } else if function == "getMovie" { // Get the catalog details of a Movie
+        return t.getMovie(stub, args)
+    } else if function == "searchMovies" { // Find Movies of the catalog by the start of their title
+        return t.searchMovies(stub, args)
+    } else if function == "initMovieDetails" { //creates a new entry for Movie
         return t.initMovieDetails(stub, args)
+    } else if function == "updateShow" { // Reschedule a show, move it to another Screen or change its capacity
+        return t.updateShow(stub, args)
+    } else if function == "cancelShow" { // Cancel a show and mark its Bookings for refund
+        return t.cancelShow(stub, args)
     } else if function == "getMoviesByName" { // Get the Details according to the TimeSlot
         return t.getMoviesByName(stub, args)
+    } else if function == "getShowById" { // Get the Details of a show by its Show ID
+        return t.getShowById(stub, args)
+    } else if function == "getShowsByMovie" { // Get all the time slots running for a Movie
+        return t.getShowsByMovie(stub, args)
+    } else if function == "listShows" { // List every show that is playing, a page at a time
+        return t.listShows(stub, args)
+    } else if function == "queryShows" { // Find shows with a CouchDB rich query
+        return t.queryShows(stub, args)
+    } else if function == "getShowHistory" { // Get every version of a show record
+        return t.getShowHistory(stub, args)
+    } else if function == "getShowSeats" { // Get the seat inventory of a show
+        return t.getShowSeats(stub, args)
+    } else if function == "setShowPricing" { // Set the price table of a show
+        return t.setShowPricing(stub, args)
+    } else if function == "quoteShowSeats" { // Get the price of seats of a show without booking them
+        return t.quoteShowSeats(stub, args)
+    } else if function == "reserveShowSeats" { // Book seats of a show against a Booking ID
+        return t.reserveShowSeats(stub, args)
+    } else if function == "releaseShowSeats" { // Free the seats of a show booked against a Booking ID
+        return t.releaseShowSeats(stub, args)
+    } else if function == "holdShowSeats" { // Hold seats of a show against a Hold ID until an expiry time
+        return t.holdShowSeats(stub, args)
+    } else if function == "confirmHeldSeats" { // Book the seats held against a Hold ID
+        return t.confirmHeldSeats(stub, args)
+    } else if function == "releaseHeldSeats" { // Free the seats held against a Hold ID
+        return t.releaseHeldSeats(stub, args)
+    } else if function == "compactShowTickets" { // Fold the ticket count changes of a show into the show record
+        return t.compactShowTickets(stub, args)
+    } else if function == "setCleaningBuffer" { // Set the minutes a Screen is kept free between shows
+        return t.setCleaningBuffer(stub, args)
+    } else if function == "initScreen" { // Creates or redefines the seat layout of a Screen
+        return t.initScreen(stub, args)
+    } else if function == "getScreen" { // Get the seat layout of a Screen
+        return t.getScreen(stub, args)
+    } else if function == "createDummyEntries" { // To create dummy data in DB
+        return t.createDummyEntries(stub)
     }
     fmt.Println("invoke did not find func: " + function) //error
-    return shim.Error("Received unknown function invocation")
+    return domain.ErrorResponse(domain.CodeInvalidArgument, "Received unknown function invocation")
+}
+
+func(t * MovieChaincode) createDummyEntries(stub shim.ChaincodeStubInterface) pb.Response {
+
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    // Both dummy screens have 10 rows of 10 seats, Standard in front, Premium behind and Recliners at the back
+    dummyRows := []ScreenRow{}
+    for _, rowLabel := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"} {
+        category := "Standard"
+        if rowLabel >= "F" && rowLabel < "J" {
+            category = "Premium"
+        } else if rowLabel == "J" {
+            category = "Recliner"
+        }
+        dummyRows = append(dummyRows, ScreenRow{RowLabel: rowLabel, SeatsPerRow: 10, Category: category, Aisles: []int{5}})
+    }
+    screensList := []Screen{
+        Screen{ScreenId: "SCREEN-1", ScreenName: "Audi 1", TheaterId: defaultTheaterId, Rows: dummyRows, ModificationTime: modificationTime},
+        Screen{ScreenId: "SCREEN-2", ScreenName: "Audi 2", TheaterId: defaultTheaterId, Rows: dummyRows, ModificationTime: modificationTime} }
+
+    for i := range screensList {
+        existingScreen, err := getScreenLayout(stub, screensList[i].ScreenId)
+        if err != nil {
+            return domain.Failed(err)
+        } else if existingScreen != nil {
+            continue
+        }
+        err = putScreen(stub, &screensList[i])
+        if err != nil {
+            return domain.Failed(err)
+        }
+    }
+
+    moviesList := []domain.Movie{
+        domain.Movie{MovieId: "the-grudge-2020", Title: "The Grudge", Genre: "Horror", Language: "English", RuntimeMinutes: 94, Rating: "A", ReleaseDate: "2020-01-03", ModificationTime: modificationTime},
+        domain.Movie{MovieId: "the-godfather-1972", Title: "The Godfather", Genre: "Crime", Language: "English", RuntimeMinutes: 175, Rating: "A", ReleaseDate: "1972-03-24", ModificationTime: modificationTime},
+        domain.Movie{MovieId: "the-dark-knight-2008", Title: "The Dark Knight", Genre: "Action", Language: "English", RuntimeMinutes: 152, Rating: "UA", ReleaseDate: "2008-07-18", ModificationTime: modificationTime} }
+
+    for i := range moviesList {
+        existingMovie, err := getCatalogMovie(stub, moviesList[i].MovieId)
+        if err != nil {
+            return domain.Failed(err)
+        } else if existingMovie != nil {
+            moviesList[i] = *existingMovie
+            continue
+        }
+        err = putMovie(stub, &moviesList[i])
+        if err != nil {
+            return domain.Failed(err)
+        }
+    }
+
+    // The dummy shows run on the day after the transaction, so every endorser creates the same ones
+    showDay := modificationTime.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	movieDetailsList := []domain.Show{
+        dummyShow(&moviesList[0], showDay.Add(9 * time.Hour), "SCREEN-1", 100),
+        dummyShow(&moviesList[0], showDay.Add(12 * time.Hour), "SCREEN-1", 100),
+        dummyShow(&moviesList[0], showDay.Add(18 * time.Hour), "SCREEN-1", 3),
+        dummyShow(&moviesList[1], showDay.Add(9 * time.Hour), "SCREEN-2", 0),
+        dummyShow(&moviesList[1], showDay.Add(13 * time.Hour), "SCREEN-2", 100),
+        dummyShow(&moviesList[2], showDay.Add(18 * time.Hour), "SCREEN-2", 100) }
+    for i := range movieDetailsList {
+        movieDetailsList[i].ModificationTime = modificationTime
+    }
+
+	i := 0
+	for i < len(movieDetailsList) {
+		fmt.Println("i is ", i)
+		existingShow, err := getShow(stub, movieDetailsList[i].MovieId, movieDetailsList[i].AvailalbeTimeSlots)
+		if err != nil {
+			return domain.Failed(err)
+		}
+		if existingShow == nil {
+			err = createShow(stub, &movieDetailsList[i])
+			if err != nil {
+				return domain.Failed(err)
+			}
+			fmt.Println("Added", movieDetailsList[i])
+		}
+		i = i + 1
+	}
+
+	return shim.Success(nil)
+}
+
+// dummyShow - A show of the dummy data of a movie of the catalog starting at a time
+func dummyShow(movie *domain.Movie, startTime time.Time, screenId string, remainingTickets int) domain.Show {
+    return domain.Show {
+        MovieId: movie.MovieId,
+        MovieName: movie.Title,
+        AvailalbeTimeSlots: startTime.Format(time.RFC3339),
+        StartTime: startTime,
+        RuntimeMinutes: movie.RuntimeMinutes,
+        EndTime: startTime.Add(time.Duration(movie.RuntimeMinutes) * time.Minute),
+        ScreenId: screenId,
+        RemainingTickets: remainingTickets }
 }
 
-// initMovieDetails - Creating record Movie name, time slots and total ticket for a show
+// initMovieDetails - Creating record of a show: Movie ID or title of a movie of the catalog, RFC 3339 start time and
+// the Screen of the show. The start time is stored in UTC as the time slot, the end time is worked out from the runtime of the movie
+// and the tickets come from the Screen layout. Returns the show with its Show ID.
 func(t * MovieChaincode) initMovieDetails(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
 
 	logger.Info("########### START - initMovieDetails ###########")
 	
     var err error
-    if len(args) != 5 {
-        return shim.Error("Incorrect number of arguments. Expecting 5")
+    if len(args) != 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Start time and Screen ID")
     }
 
     // Initializing the primary parameters for Movies
-    movieName := args[0]
-    availalbeTimeSlots := args[1]
-    totalTickets, err := strconv.Atoi(args[2])
+    startTime, err := domain.ParseStartTime(args[1])
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, err.Error())
+    }
+    screenId := strings.TrimSpace(args[2])
+    availalbeTimeSlots := startTime.Format(time.RFC3339)
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    movie, err := resolveMovie(stub, args[0])
+    if err != nil {
+        return domain.Failed(err)
+    } else if movie == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie found in the catalog for the requested Movie ID or title: " + args[0])
+    }
+    movieId := movie.MovieId
+    movieName := movie.Title
+    runtimeMinutes := movie.RuntimeMinutes
+
+    existingShow, err := getShow(stub, movieId, availalbeTimeSlots)
     if err != nil {
-        return shim.Error("Expecting integer value for Remaining Tickets")
+        return domain.Failed(err)
+    } else if existingShow != nil {
+        return domain.ErrorResponse(domain.CodeConflict, "Movie show of " + movieName + " already exists for the time slot: " + availalbeTimeSlots)
     }
-    remainingTickets, err := strconv.Atoi(args[3])
+
+    screen, err := getScreenLayout(stub, screenId)
     if err != nil {
-        return shim.Error("Expecting integer value for Remaining Tickets")
+        return domain.Failed(err)
+    } else if screen == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Screen found for the requested Screen ID: " + screenId)
     }
-    houseFullFlag := args[4]
 
-	logger.Info("Details about Movie: \n", movieName, availalbeTimeSlots, totalTickets, remainingTickets)
+	logger.Info("Details about Movie: \n", movieName, availalbeTimeSlots, runtimeMinutes, screenId, screen.TotalSeats)
 
     // ==== Create  ====
-    MoviesList := &MovieDetails {
+    MoviesList := &domain.Show {
+        MovieId: movieId,
         MovieName: movieName,
         AvailalbeTimeSlots: availalbeTimeSlots,
-        TotalTickets: totalTickets,
-        RemainingTickets: remainingTickets,
-        HouseFullFlag: houseFullFlag,
-        ModificationTime: time.Now() }
+        StartTime: startTime,
+        RuntimeMinutes: runtimeMinutes,
+        EndTime: startTime.Add(time.Duration(runtimeMinutes) * time.Minute),
+        TotalTickets: screen.TotalSeats,
+        RemainingTickets: screen.TotalSeats,
+        ScreenId: screenId,
+        ModificationTime: modificationTime }
 
-    moviesListAsBytes, err := json.Marshal(MoviesList)
+    // Write the state to the ledger
+    err = createShow(stub, MoviesList)
     if err != nil {
-        return shim.Error(err.Error())
+        return domain.Failed(err)
     }
 
-    // Write the state to the ledger
-    err = stub.PutState(movieName, moviesListAsBytes)
+    err = domain.SetEvent(stub, &domain.Event {
+        Message: "Movie record created succcessfully",
+        Movie: movieName,
+        TimeSlot: availalbeTimeSlots,
+        ShowId: MoviesList.ShowId })
     if err != nil {
-        return shim.Error(err.Error())
+        return domain.Failed(err)
     }
 
-    // Create Index
-    indexName := "indexMovieAndTime"
-    movieTimeIndexKey, err := stub.CreateCompositeKey(indexName, []string {MoviesList.MovieName, MoviesList.AvailalbeTimeSlots})
+    showAsBytes, err := json.Marshal(MoviesList)
     if err != nil {
-        return shim.Error(err.Error())
+        return domain.Failed(err)
     }
 
-    value := []byte{0x00}
-    stub.PutState(movieTimeIndexKey, value)
-    eventMessage := "{ \"Movie\" : \"" + movieName + "\", \"message\" : \"Movie record created succcessfully\", \"code\" : \"200\"}"
-    err = stub.SetEvent("evtsender", [] byte(eventMessage))
+    fmt.Println("- end Movie record creation request")
+	logger.Info("Movie record created successfully")
+    return shim.Success(showAsBytes)
+
+}
+
+// updateShow - Reschedules a show, moves it to another Screen or changes its capacity. Args are Show ID, Start time,
+// Screen ID and Capacity, empty ones keep their value. The Show ID keeps naming the start time the show was created
+// with. A show moved to another Screen takes all its seats unless a Capacity is given; booked and held seats keep
+// their Seat Numbers, so the Screen must have them, and the capacity cannot drop below them.
+func(t * MovieChaincode) updateShow(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - updateShow ###########")
+
+    if len(args) != 4 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID, Start time, Screen ID and Capacity, empty ones keep their value")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
     if err != nil {
-        return shim.Error(err.Error())
+        return domain.Failed(err)
     }
 
-    fmt.Println("- end Movie record creation request")
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No show found for the requested Show ID: " + args[0])
+    } else if show.ShowStatus == "Cancelled" {
+        return domain.ErrorResponse(domain.CodeConflict, "Show " + show.ShowId + " is cancelled")
+    }
 
-    return shim.Success(nil)
+    // Taking the show off the schedule of its Screen until it is written back with its new time and Screen
+    err = removeFromSchedule(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    startTime := strings.TrimSpace(args[1])
+    if startTime != "" {
+        if show.RuntimeMinutes == 0 {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Show " + show.ShowId + " has no runtime and cannot be rescheduled")
+        }
+        show.StartTime, err = domain.ParseStartTime(startTime)
+        if err != nil {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, err.Error())
+        }
+        show.EndTime = show.StartTime.Add(time.Duration(show.RuntimeMinutes) * time.Minute)
+    }
+
+    screenId := strings.TrimSpace(args[2])
+    screenChanged := screenId != "" && screenId != show.ScreenId
+    capacity := show.TotalTickets
+    if screenChanged {
+        show.ScreenId = screenId
+        capacity = 0
+    }
+    if strings.TrimSpace(args[3]) != "" {
+        capacity, err = strconv.Atoi(strings.TrimSpace(args[3]))
+        if err != nil || capacity <= 0 {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a positive integer value for Capacity")
+        }
+    }
+
+    if screenChanged || capacity != show.TotalTickets {
+        if show.ScreenId == "" {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Show " + show.ShowId + " has no Screen, give it one to change its capacity")
+        }
+        screen, err := getScreenLayout(stub, show.ScreenId)
+        if err != nil {
+            return domain.Failed(err)
+        } else if screen == nil {
+            return domain.ErrorResponse(domain.CodeNotFound, "No Screen found for the requested Screen ID: " + show.ScreenId)
+        }
+        if capacity == 0 {
+            capacity = len(layoutSeats(screen))
+        }
+
+        err = layoutShowSeats(stub, show, screen, capacity)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        // Bookings since the last compaction are in the ticket deltas, the stored count moves by the capacity change
+        show.RemainingTickets = show.RemainingTickets + capacity - show.TotalTickets
+        show.TotalTickets = capacity
+        show.TheaterId = screen.TheaterId
+    }
+
+    err = checkSchedule(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    show.ModificationTime, err = txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    err = putShow(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    err = domain.SetEvent(stub, &domain.Event{
+        Message: "Show updated successfully",
+        Movie: show.MovieName,
+        TimeSlot: show.AvailalbeTimeSlots,
+        ShowId: show.ShowId,
+        Screen: show.ScreenId })
+    if err != nil {
+        return domain.Failed(err)
+    }
 
+    err = deriveRemainingTickets(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    showAsBytes, err := json.Marshal(show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(showAsBytes)
 }
 
-func(t * MovieChaincode) getMoviesByName(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
-    var movieName, jsonResp string
-    var err error
+// cancelShow - Cancels a show. Args are Show ID and optionally the reason. The show stops selling seats and leaves
+// the schedule of its Screen, and the Bookings chaincode marks every booking of it RefundDue. The showCancelled
+// event lists those bookings for the refunds. Nothing is cancelled when the Bookings chaincode fails to mark them.
+func(t * MovieChaincode) cancelShow(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - cancelShow ###########")
+
+    if len(args) != 1 && len(args) != 2 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID and optionally the reason")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+    reason := ""
+    if len(args) == 2 {
+        reason = strings.TrimSpace(args[1])
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No show found for the requested Show ID: " + args[0])
+    } else if show.ShowStatus == "Cancelled" {
+        return domain.ErrorResponse(domain.CodeConflict, "Show " + show.ShowId + " is already cancelled")
+    }
+
+    err = removeFromSchedule(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    show.ShowStatus = "Cancelled"
+    show.ModificationTime, err = txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    err = putShow(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    // ---- CALLING BOOKINGS CHAINCODE TO MARK THE BOOKINGS FOR REFUND ---- //
+    response := invokeBookings(stub, util.ToChaincodeArgs("markShowRefundDue", show.MovieId, show.AvailalbeTimeSlots))
+    if response.Status != shim.OK {
+        return domain.Failed(domain.UpstreamError("Bookings chaincode", response))
+    }
+    refunds := []domain.RefundDueBooking{}
+    err = json.Unmarshal(response.Payload, &refunds)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    err = domain.SetShowCancelledEvent(stub, &domain.ShowCancelledEvent{
+        Message: "Show cancelled",
+        ShowId: show.ShowId,
+        MovieId: show.MovieId,
+        MovieName: show.MovieName,
+        TimeSlot: show.AvailalbeTimeSlots,
+        Reason: reason,
+        Refunds: refunds })
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Show cancelled: ", show.ShowId, ", bookings due a refund: ", len(refunds))
+    err = deriveRemainingTickets(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    showAsBytes, err := json.Marshal(show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(showAsBytes)
+}
+
+// registerMovie - Adds a Movie to the catalog. Args are Movie ID, Title, Runtime in minutes and optionally Genre,
+// Language, Rating, Release date (YYYY-MM-DD) and Poster URL. The Movie ID is lowercased, so it can be given in any case.
+func(t * MovieChaincode) registerMovie(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) < 3 || len(args) > 8 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Title, Runtime in minutes and optionally Genre, Language, Rating, Release date and Poster URL")
+    }
+    movieId := canonicalMovieId(args[0])
+    if !movieIdPattern.MatchString(movieId) {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a Movie ID of lowercase letters, digits and dashes such as inception-2010")
+    }
+
+    existingMovie, err := getCatalogMovie(stub, movieId)
+    if err != nil {
+        return domain.Failed(err)
+    } else if existingMovie != nil {
+        return domain.ErrorResponse(domain.CodeConflict, "Movie " + movieId + " is already in the catalog")
+    }
+
+    movie := &domain.Movie{MovieId: movieId}
+    err = setMovieDetails(movie, args[1:])
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return saveMovie(stub, movie, "Movie registered succcessfully")
+}
+
+// updateMovie - Corrects the catalog details of a Movie. Args are Movie ID and the details in the order of
+// registerMovie, empty or left out details keep their value. Shows already scheduled keep their runtime.
+func(t * MovieChaincode) updateMovie(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) < 2 || len(args) > 8 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID and Title, Runtime in minutes, Genre, Language, Rating, Release date and Poster URL")
+    }
+    movieId := canonicalMovieId(args[0])
+
+    movie, err := getCatalogMovie(stub, movieId)
+    if err != nil {
+        return domain.Failed(err)
+    } else if movie == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie found in the catalog for the requested Movie ID: " + movieId)
+    }
+
+    err = setMovieDetails(movie, args[1:])
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return saveMovie(stub, movie, "Movie updated succcessfully")
+}
+
+// getMovie - Catalog details of a Movie named by its Movie ID or title
+func(t * MovieChaincode) getMovie(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
     if len(args) != 1 {
-        return shim.Error("Incorrect number of arguments. Expecting Time Slot to fetch the details")
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID or title to fetch the details")
     }
 
-	movieName = args[0]
-    valAsbytes, err := stub.GetState(movieName) //get the Incident details from chaincode state
+    movie, err := resolveMovie(stub, args[0])
     if err != nil {
-        jsonResp = "{\"Error\":\"Failed to get state for given TimeSlot" + movieName + "\"}"
-        return shim.Error(jsonResp)
-    } else if valAsbytes == nil {
-        jsonResp = "{\"Error\":\"No Movie show is running for the requested time slot: " + movieName + "\"}"
-        return shim.Error(jsonResp)
+        return domain.Failed(err)
+    } else if movie == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie found in the catalog for the requested Movie ID or title: " + args[0])
+    }
+
+    movieAsBytes, err := json.Marshal(movie)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(movieAsBytes)
+}
+
+// setMovieDetails - Applies Title, Runtime in minutes, Genre, Language, Rating, Release date and Poster URL to a
+// Movie, in that order. Empty or left out details keep the value the Movie has, the result must have a Title and
+// a Runtime.
+func setMovieDetails(movie *domain.Movie, details []string) error {
+
+    fields := make([]string, 7)
+    for i, detail := range details {
+        fields[i] = strings.TrimSpace(detail)
+    }
+
+    if fields[0] != "" {
+        movie.Title = canonicalTitle(fields[0])
+    }
+    if fields[1] != "" {
+        runtimeMinutes, err := strconv.Atoi(fields[1])
+        if err != nil || runtimeMinutes <= 0 || runtimeMinutes > maxRuntimeMinutes {
+            return domain.NewError(domain.CodeInvalidArgument, "Expecting Runtime in minutes between 1 and %d", maxRuntimeMinutes)
+        }
+        movie.RuntimeMinutes = runtimeMinutes
+    }
+    if fields[2] != "" {
+        movie.Genre = fields[2]
+    }
+    if fields[3] != "" {
+        movie.Language = fields[3]
+    }
+    if fields[4] != "" {
+        movie.Rating = fields[4]
+    }
+    if fields[5] != "" {
+        _, err := time.Parse("2006-01-02", fields[5])
+        if err != nil {
+            return domain.NewError(domain.CodeInvalidArgument, "Expecting the Release date as YYYY-MM-DD")
+        }
+        movie.ReleaseDate = fields[5]
+    }
+    if fields[6] != "" {
+        posterUrl, err := url.Parse(fields[6])
+        if err != nil || (posterUrl.Scheme != "http" && posterUrl.Scheme != "https") || posterUrl.Host == "" {
+            return domain.NewError(domain.CodeInvalidArgument, "Expecting an http or https Poster URL")
+        }
+        movie.PosterUrl = fields[6]
+    }
+
+    if movie.Title == "" || movie.RuntimeMinutes == 0 {
+        return domain.NewError(domain.CodeInvalidArgument, "Expecting a Title and a Runtime in minutes")
+    }
+    return nil
+}
+
+// saveMovie - Writes a Movie of the catalog stamped with the transaction time and returns it, with an event
+func saveMovie(stub shim.ChaincodeStubInterface, movie *domain.Movie, message string) pb.Response {
+
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    movie.ModificationTime = modificationTime
+
+    err = putMovie(stub, movie)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    err = domain.SetEvent(stub, &domain.Event{Message: message, Movie: movie.Title, MovieId: movie.MovieId})
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    movieAsBytes, err := json.Marshal(movie)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(movieAsBytes)
+}
+
+// getCatalogMovie - Reads a Movie of the catalog, nil when no Movie has the Movie ID
+func getCatalogMovie(stub shim.ChaincodeStubInterface, movieId string) (*domain.Movie, error) {
+
+    movieKey, err := domain.MovieKey(stub, movieId)
+    if err != nil {
+        return nil, err
+    }
+
+    movieAsBytes, err := stub.GetState(movieKey)
+    if err != nil || movieAsBytes == nil {
+        return nil, err
+    }
+    return domain.UnmarshalMovie(movieAsBytes)
+}
+
+// putMovie - Writes a Movie of the catalog and indexes it against its normalized title, moving the index entry when
+// the title changed
+func putMovie(stub shim.ChaincodeStubInterface, movie *domain.Movie) error {
+
+    movie.DocType = domain.MovieDocType
+    movie.NormalizedTitle = normalizeTitle(movie.Title)
+    movieKey, err := domain.MovieKey(stub, movie.MovieId)
+    if err != nil {
+        return err
+    }
+
+    storedMovie, err := getCatalogMovie(stub, movie.MovieId)
+    if err != nil {
+        return err
+    } else if storedMovie != nil && storedMovie.NormalizedTitle != movie.NormalizedTitle {
+        err = stub.DelState(movieTitleIndexKey(storedMovie.NormalizedTitle, movie.MovieId))
+        if err != nil {
+            return err
+        }
+    }
+
+    movieAsBytes, err := json.Marshal(movie)
+    if err != nil {
+        return err
+    }
+    err = stub.PutState(movieKey, movieAsBytes)
+    if err != nil {
+        return err
+    }
+
+    value := []byte{0x00}
+    return stub.PutState(movieTitleIndexKey(movie.NormalizedTitle, movie.MovieId), value)
+}
+
+// searchMovies - Movies of the catalog whose title starts with the given text, in title order. Titles are compared
+// normalized, so the search ignores case and extra whitespace. An empty text lists the whole catalog.
+func(t * MovieChaincode) searchMovies(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting the start of a title to search for")
+    }
+
+    movies, err := findMoviesByTitle(stub, normalizeTitle(args[0]), false)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    moviesAsBytes, err := json.Marshal(movies)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(moviesAsBytes)
+}
+
+// resolveMovie - Movie of the catalog named by its Movie ID in any case or by its title, nil when there is none.
+// A title several movies share is a conflict, those have to be named by Movie ID.
+func resolveMovie(stub shim.ChaincodeStubInterface, name string) (*domain.Movie, error) {
+
+    movie, err := getCatalogMovie(stub, canonicalMovieId(name))
+    if err != nil || movie != nil {
+        return movie, err
+    }
+
+    normalizedTitle := normalizeTitle(name)
+    if normalizedTitle == "" {
+        return nil, nil
+    }
+    movies, err := findMoviesByTitle(stub, normalizedTitle, true)
+    if err != nil || len(movies) == 0 {
+        return nil, err
+    } else if len(movies) > 1 {
+        movieIds := []string{}
+        for _, titleMovie := range movies {
+            movieIds = append(movieIds, titleMovie.MovieId)
+        }
+        return nil, domain.NewError(domain.CodeConflict, "Title %s is shared by the movies %s, name the movie by its Movie ID", name, strings.Join(movieIds, ", "))
+    }
+    return &movies[0], nil
+}
+
+// findMoviesByTitle - Movies whose normalized title starts with a normalized prefix, or equals it when wholeTitle is
+// set, range scanning the indexMovieTitle index
+func findMoviesByTitle(stub shim.ChaincodeStubInterface, normalizedTitle string, wholeTitle bool) ([]domain.Movie, error) {
+
+    startKey := movieTitleIndex + "\x00" + normalizedTitle
+    if wholeTitle {
+        startKey += "\x00"
+    }
+    resultsIterator, err := stub.GetStateByRange(startKey, startKey + string(utf8.MaxRune))
+    if err != nil {
+        return nil, err
+    }
+    defer resultsIterator.Close()
+
+    movies := []domain.Movie{}
+    for resultsIterator.HasNext() {
+        indexEntry, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+
+        movieId := indexEntry.Key[strings.LastIndex(indexEntry.Key, "\x00") + 1:]
+        movie, err := getCatalogMovie(stub, movieId)
+        if err != nil {
+            return nil, err
+        } else if movie != nil {
+            movies = append(movies, *movie)
+        }
+    }
+    return movies, nil
+}
+
+// movieTitleIndexKey - Key of a Movie in the indexMovieTitle index. The parts are separated by U+0000 like those of
+// a composite key, which sorts a title before the longer titles it is a prefix of.
+func movieTitleIndexKey(normalizedTitle string, movieId string) string {
+    return movieTitleIndex + "\x00" + normalizedTitle + "\x00" + movieId
+}
+
+// canonicalTitle - Title as the catalog stores it: composed Unicode (NFC) with its whitespace collapsed to single spaces
+func canonicalTitle(title string) string {
+    return strings.Join(strings.Fields(norm.NFC.String(title)), " ")
+}
+
+// normalizeTitle - Title as lookups and searches compare it: compatibility composed Unicode (NFKC), case folded and
+// with its whitespace collapsed, so "THE  Grudge" and "the grudge" are the same title
+func normalizeTitle(title string) string {
+    folded := strings.Map(func(r rune) rune {
+        return unicode.ToLower(unicode.ToUpper(r))
+    }, norm.NFKC.String(title))
+    return strings.Join(strings.Fields(folded), " ")
+}
+
+// canonicalMovieId - Movie ID as the catalog keys it. Movie IDs are lowercase, so any case of one finds the Movie.
+func canonicalMovieId(movieId string) string {
+    return strings.ToLower(strings.TrimSpace(norm.NFKC.String(movieId)))
+}
+
+// txTime - Timestamp of the transaction proposal, identical on every endorsing peer unlike time.Now()
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+    txTimestamp, err := stub.GetTxTimestamp()
+    if err != nil {
+        return time.Time{}, err
+    }
+    return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), nil
+}
+
+// putShow - Writes the show under its Show ID, the Movie and Time slot key, and indexes it against the Movie and
+// against its Screen and day
+func putShow(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    // Times are stored in UTC to the second, so that rich queries compare them in order as strings
+    show.StartTime = show.StartTime.UTC().Truncate(time.Second)
+    show.EndTime = show.EndTime.UTC().Truncate(time.Second)
+    show.ModificationTime = show.ModificationTime.UTC().Truncate(time.Second)
+    show.DocType = domain.ShowDocType
+    show.ShowId = domain.ShowKey(show.MovieId, show.AvailalbeTimeSlots)
+    showAsBytes, err := json.Marshal(show)
+    if err != nil {
+        return err
     }
 
-    return shim.Success(valAsbytes)
-}
\ No newline at end of file
+    err = stub.PutState(show.ShowId, showAsBytes)
+    if err != nil {
+        return err
+    }
+
+    // Create Index
+    movieTimeIndexKey, err := domain.MovieTimeIndexKey(stub, show.MovieId, show.AvailalbeTimeSlots)
+    if err != nil {
+        return err
+    }
+
+    value := []byte{0x00}
+    err = stub.PutState(movieTimeIndexKey, value)
+    if err != nil {
+        return err
+    }
+
+    // Shows without a start time cannot be placed on the Screen's schedule, cancelled shows leave it
+    if show.StartTime.IsZero() || show.ScreenId == "" || show.ShowStatus == "Cancelled" {
+        return nil
+    }
+    screenDayIndexKey, err := stub.CreateCompositeKey(screenDayIndex, []string {show.ScreenId, show.StartTime.Format(scheduleDayFormat), show.ShowId})
+    if err != nil {
+        return err
+    }
+    return stub.PutState(screenDayIndexKey, value)
+}
+
+// removeFromSchedule - Deletes the indexScreenDay key of a show, before it moves or when it is cancelled
+func removeFromSchedule(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    if show.StartTime.IsZero() || show.ScreenId == "" {
+        return nil
+    }
+    screenDayIndexKey, err := stub.CreateCompositeKey(screenDayIndex, []string {show.ScreenId, show.StartTime.Format(scheduleDayFormat), show.ShowId})
+    if err != nil {
+        return err
+    }
+    return stub.DelState(screenDayIndexKey)
+}
+
+// createShow - Writes a new show with its capacity and seat inventory generated from the layout of its Screen.
+// RemainingTickets is kept as given (up to the capacity) and the seats already sold are marked Booked. A show
+// overlapping another show of its Screen, cleaning buffer included, is rejected with a CONFLICT.
+func createShow(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    screen, err := getScreenLayout(stub, show.ScreenId)
+    if err != nil {
+        return err
+    } else if screen == nil {
+        return domain.NewError(domain.CodeNotFound, "Screen %s does not exist", show.ScreenId)
+    }
+
+    err = checkSchedule(stub, show)
+    if err != nil {
+        return err
+    }
+
+    seatsList := layoutSeats(screen)
+    show.ShowStatus = "Scheduled"
+    show.TheaterId = screen.TheaterId
+    show.TotalTickets = len(seatsList)
+    if show.RemainingTickets > show.TotalTickets || show.RemainingTickets < 0 {
+        show.RemainingTickets = show.TotalTickets
+    }
+    if show.RemainingTickets == 0 {
+        show.HouseFullFlag = "True"
+    } else {
+        show.HouseFullFlag = "False"
+    }
+
+    err = putShow(stub, show)
+    if err != nil {
+        return err
+    }
+
+    soldTickets := show.TotalTickets - show.RemainingTickets
+    for i, seat := range seatsList {
+        seat.MovieId = show.MovieId
+        seat.MovieName = show.MovieName
+        seat.TimeSlot = show.AvailalbeTimeSlots
+        seat.Status = "Free"
+        if i < soldTickets {
+            seat.Status = "Booked"
+        }
+
+        err = putSeat(stub, &seat)
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// checkSchedule - Errors with a CONFLICT when another show of the Screen of a show runs within the cleaning buffer of it
+func checkSchedule(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    clash, bufferMinutes, err := findScheduleClash(stub, show)
+    if err != nil {
+        return err
+    } else if clash != nil {
+        return domain.NewError(domain.CodeConflict, "Screen %s is taken by show %s from %s to %s, shows need %d minutes between them for cleaning",
+            show.ScreenId, clash.ShowId, clash.StartTime.Format(time.RFC3339), clash.EndTime.Format(time.RFC3339), bufferMinutes)
+    }
+    return nil
+}
+
+// layoutShowSeats - Lays the seat inventory of a show out on a Screen for the given capacity. Booked and held seats
+// keep their Seat Number and status, so the Screen must have them and the capacity must cover them. The other seats
+// of the Screen are Free in layout order up to the capacity and Blocked after it, the seats the Screen does not have
+// are deleted.
+func layoutShowSeats(stub shim.ChaincodeStubInterface, show *domain.Show, screen *Screen, capacity int) error {
+
+    currTime, err := txTime(stub)
+    if err != nil {
+        return err
+    }
+    seatsList := layoutSeats(screen)
+    if capacity > len(seatsList) {
+        return domain.NewError(domain.CodeInvalidArgument, "Screen %s has %d seats, the capacity cannot be more", screen.ScreenId, len(seatsList))
+    }
+    onScreen := map[string]bool{}
+    for _, seat := range seatsList {
+        onScreen[seat.SeatNumber] = true
+    }
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(domain.ShowSeatObject, []string {show.MovieId, show.AvailalbeTimeSlots})
+    if err != nil {
+        return err
+    }
+    defer resultsIterator.Close()
+
+    takenSeats := map[string]bool{}
+    leftOffSeats := []string{}
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+        seat, err := domain.UnmarshalSeat(responseRange.Value)
+        if err != nil {
+            return err
+        }
+
+        taken := seat.Status != "Blocked" && !seatIsFree(seat, currTime)
+        if taken && !onScreen[seat.SeatNumber] {
+            return domain.NewError(domain.CodeConflict, "Seat %s of show %s is %s and Screen %s has no such seat", seat.SeatNumber, show.ShowId, strings.ToLower(seat.Status), screen.ScreenId)
+        } else if taken {
+            takenSeats[seat.SeatNumber] = true
+        } else if !onScreen[seat.SeatNumber] {
+            leftOffSeats = append(leftOffSeats, responseRange.Key)
+        }
+    }
+    if capacity < len(takenSeats) {
+        return domain.NewError(domain.CodeConflict, "Show %s has %d seats sold or held, the capacity cannot be less", show.ShowId, len(takenSeats))
+    }
+
+    freeSeats := capacity - len(takenSeats)
+    for _, seat := range seatsList {
+        if takenSeats[seat.SeatNumber] {
+            continue
+        }
+        seat.MovieId = show.MovieId
+        seat.MovieName = show.MovieName
+        seat.TimeSlot = show.AvailalbeTimeSlots
+        seat.Status = "Blocked"
+        if freeSeats > 0 {
+            seat.Status = "Free"
+            freeSeats = freeSeats - 1
+        }
+
+        err = putSeat(stub, &seat)
+        if err != nil {
+            return err
+        }
+    }
+
+    for _, seatKey := range leftOffSeats {
+        err = stub.DelState(seatKey)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// findScheduleClash - First other show of the Screen of a show that runs within the cleaning buffer of it, nil when
+// the Screen is free. The shows are found through the indexScreenDay index, starting from the earliest day a show
+// running into this one could have started on. Also returns the cleaning buffer in minutes.
+func findScheduleClash(stub shim.ChaincodeStubInterface, show *domain.Show) (*domain.Show, int, error) {
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return nil, 0, err
+    }
+    bufferMinutes := config.CleaningBufferMinutes
+    if show.StartTime.IsZero() || show.ScreenId == "" {
+        return nil, bufferMinutes, nil
+    }
+    buffer := time.Duration(bufferMinutes) * time.Minute
+
+    firstDay := show.StartTime.Add(-time.Duration(maxRuntimeMinutes) * time.Minute - buffer).Truncate(24 * time.Hour)
+    lastDay := show.EndTime.Add(buffer).Truncate(24 * time.Hour)
+    for day := firstDay; !day.After(lastDay); day = day.Add(24 * time.Hour) {
+        resultsIterator, err := stub.GetStateByPartialCompositeKey(screenDayIndex, []string {show.ScreenId, day.Format(scheduleDayFormat)})
+        if err != nil {
+            return nil, bufferMinutes, err
+        }
+
+        for resultsIterator.HasNext() {
+            responseRange, err := resultsIterator.Next()
+            if err != nil {
+                resultsIterator.Close()
+                return nil, bufferMinutes, err
+            }
+
+            _, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+            if err != nil {
+                resultsIterator.Close()
+                return nil, bufferMinutes, err
+            }
+            if compositeKeyParts[2] == domain.ShowKey(show.MovieId, show.AvailalbeTimeSlots) {
+                continue
+            }
+
+            showAsBytes, err := stub.GetState(compositeKeyParts[2])
+            if err != nil {
+                resultsIterator.Close()
+                return nil, bufferMinutes, err
+            } else if showAsBytes == nil {
+                continue
+            }
+            other, err := domain.UnmarshalShow(showAsBytes)
+            if err != nil {
+                resultsIterator.Close()
+                return nil, bufferMinutes, err
+            }
+
+            if show.StartTime.Before(other.EndTime.Add(buffer)) && other.StartTime.Before(show.EndTime.Add(buffer)) {
+                resultsIterator.Close()
+                return other, bufferMinutes, nil
+            }
+        }
+        resultsIterator.Close()
+    }
+
+    return nil, bufferMinutes, nil
+}
+
+// setCleaningBuffer - Sets the minutes a Screen is kept free between the end of a show and the start of the next.
+// Shows already scheduled are not checked again.
+func(t * MovieChaincode) setCleaningBuffer(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Cleaning buffer in minutes")
+    }
+    bufferMinutes, err := strconv.Atoi(args[0])
+    if err != nil || bufferMinutes < 0 || bufferMinutes > maxRuntimeMinutes {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Cleaning buffer in minutes between 0 and " + strconv.Itoa(maxRuntimeMinutes))
+    }
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    config.CleaningBufferMinutes = bufferMinutes
+    err = putMovieConfig(stub, config)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(nil)
+}
+
+// layoutSeats - Sellable seats of a Screen in row order with their category, blocked positions are left out
+func layoutSeats(screen *Screen) []domain.Seat {
+
+    seatsList := []domain.Seat{}
+    for _, row := range screen.Rows {
+        blocked := map[int]bool{}
+        for _, position := range row.Blocked {
+            blocked[position] = true
+        }
+
+        position := 1
+        for position <= row.SeatsPerRow {
+            if !blocked[position] {
+                seatsList = append(seatsList, domain.Seat{SeatNumber: row.RowLabel + strconv.Itoa(position), Category: row.Category})
+            }
+            position = position + 1
+        }
+    }
+    return seatsList
+}
+
+// showSeatRows - Seat Numbers of a show row by row in the order seats are handed out, a row being split where a
+// position is blocked. Shows created before Screens existed have no Screen ID and are one row numbered 1 to TotalTickets.
+func showSeatRows(stub shim.ChaincodeStubInterface, show *domain.Show) ([][]string, error) {
+
+    if show.ScreenId == "" {
+        seatNumbers := []string{}
+        i := 1
+        for i <= show.TotalTickets {
+            seatNumbers = append(seatNumbers, strconv.Itoa(i))
+            i = i + 1
+        }
+        return [][]string{seatNumbers}, nil
+    }
+
+    screen, err := getScreenLayout(stub, show.ScreenId)
+    if err != nil {
+        return nil, err
+    } else if screen == nil {
+        return nil, domain.NewError(domain.CodeNotFound, "Screen %s does not exist", show.ScreenId)
+    }
+
+    seatRows := [][]string{}
+    for _, row := range screen.Rows {
+        blocked := map[int]bool{}
+        for _, position := range row.Blocked {
+            blocked[position] = true
+        }
+
+        seatNumbers := []string{}
+        position := 1
+        for position <= row.SeatsPerRow {
+            if !blocked[position] {
+                seatNumbers = append(seatNumbers, row.RowLabel + strconv.Itoa(position))
+            } else if len(seatNumbers) > 0 {
+                seatRows = append(seatRows, seatNumbers)
+                seatNumbers = []string{}
+            }
+            position = position + 1
+        }
+        if len(seatNumbers) > 0 {
+            seatRows = append(seatRows, seatNumbers)
+        }
+    }
+    return seatRows, nil
+}
+
+// getScreenLayout - Reads a Screen, nil when no Screen exists with the Screen ID
+func getScreenLayout(stub shim.ChaincodeStubInterface, screenId string) (*Screen, error) {
+
+    screenKey, err := stub.CreateCompositeKey(screenObject, []string {screenId})
+    if err != nil {
+        return nil, err
+    }
+
+    screenAsBytes, err := stub.GetState(screenKey)
+    if err != nil || screenAsBytes == nil {
+        return nil, err
+    }
+
+    var screen Screen
+    err = json.Unmarshal(screenAsBytes, &screen)
+    if err != nil {
+        return nil, err
+    }
+    return &screen, nil
+}
+
+// putScreen - Writes a Screen, working out its TotalSeats from the layout
+func putScreen(stub shim.ChaincodeStubInterface, screen *Screen) error {
+
+    screen.TotalSeats = len(layoutSeats(screen))
+
+    screenKey, err := stub.CreateCompositeKey(screenObject, []string {screen.ScreenId})
+    if err != nil {
+        return err
+    }
+
+    screenAsBytes, err := json.Marshal(screen)
+    if err != nil {
+        return err
+    }
+    return stub.PutState(screenKey, screenAsBytes)
+}
+
+// getShow - Reads a show, nil when no show is running for the Movie at the Time slot
+func getShow(stub shim.ChaincodeStubInterface, movieId string, timeSlot string) (*domain.Show, error) {
+
+    showAsBytes, err := stub.GetState(domain.ShowKey(movieId, timeSlot))
+    if err != nil || showAsBytes == nil {
+        return nil, err
+    }
+
+    return domain.UnmarshalShow(showAsBytes)
+}
+
+// getSeat - Reads a seat of the seat inventory, nil when the show has no such seat
+func getSeat(stub shim.ChaincodeStubInterface, movieId string, timeSlot string, seatNumber string) (*domain.Seat, error) {
+
+    seatKey, err := domain.SeatKey(stub, movieId, timeSlot, seatNumber)
+    if err != nil {
+        return nil, err
+    }
+
+    seatAsBytes, err := stub.GetState(seatKey)
+    if err != nil || seatAsBytes == nil {
+        return nil, err
+    }
+
+    return domain.UnmarshalSeat(seatAsBytes)
+}
+
+// putSeat - Writes a seat of the seat inventory
+func putSeat(stub shim.ChaincodeStubInterface, seat *domain.Seat) error {
+
+    seatKey, err := domain.SeatKey(stub, seat.MovieId, seat.TimeSlot, seat.SeatNumber)
+    if err != nil {
+        return err
+    }
+
+    seatAsBytes, err := json.Marshal(seat)
+    if err != nil {
+        return err
+    }
+    return stub.PutState(seatKey, seatAsBytes)
+}
+
+// getMoviesByName - Details of a Movie show for the requested Time slot. The Movie is named by its Movie ID in any
+// case or by its title, shows created before the catalog existed by their Movie name.
+func(t * MovieChaincode) getMoviesByName(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+    if len(args) != 2 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID and Time Slot to fetch the details")
+    }
+
+    movieId, err := showMovieId(stub, args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return showDetails(stub, movieId, args[1])
+}
+
+// showDetails - Details of the show keyed by a Movie ID and Time slot, with the Remaining Tickets derived from its
+// ticket deltas
+func showDetails(stub shim.ChaincodeStubInterface, movieId string, timeSlot string) pb.Response {
+
+    valAsbytes, err := stub.GetState(domain.ShowKey(movieId, timeSlot)) //get the show details from chaincode state
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInternal, "Failed to get state for " + movieId + " at Time slot " + timeSlot)
+    } else if valAsbytes == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    show, err := domain.UnmarshalShow(valAsbytes)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    err = deriveRemainingTickets(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    showAsBytes, err := json.Marshal(show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(showAsBytes)
+}
+
+// getShowById - Details of a show by its Show ID, the Movie ID and start time joined by an underscore
+func(t * MovieChaincode) getShowById(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID to fetch the details")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    // The Show ID is the key of the show, so its Movie ID is not looked up in the catalog
+    return showDetails(stub, movieId, timeSlot)
+}
+
+// showMovieId - Movie ID the shows of a Movie named by Movie ID or title are keyed by. Names that are not in the catalog
+// are taken as they are, shows created before the catalog existed are keyed by their Movie name.
+func showMovieId(stub shim.ChaincodeStubInterface, name string) (string, error) {
+
+    movie, err := resolveMovie(stub, name)
+    if err != nil {
+        return "", err
+    } else if movie == nil {
+        return name, nil
+    }
+    return movie.MovieId, nil
+}
+
+// getShowsByMovie - All the time slots of a Movie named by Movie ID or title, walking the indexMovieAndTime index
+func(t * MovieChaincode) getShowsByMovie(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID to fetch the shows")
+    }
+    movieId, err := showMovieId(stub, args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(domain.MovieTimeIndex, []string {movieId})
+    if err != nil {
+        return domain.Failed(err)
+    }
+    defer resultsIterator.Close()
+
+    showsList, err := readIndexedShows(stub, resultsIterator, false)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    showsListAsBytes, err := json.Marshal(showsList)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(showsListAsBytes)
+}
+
+// listShows - Every show that is playing, a page at a time in Movie ID and Time slot order. Args are the page size,
+// the bookmark returned with the previous page (empty for the first page) and optionally True to leave out the
+// house-full shows. House-full shows still count towards the page size, so a filtered page can hold fewer shows.
+func(t * MovieChaincode) listShows(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 2 && len(args) != 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Page size, Bookmark and optionally whether to leave out house-full shows")
+    }
+    pageSize, err := strconv.Atoi(args[0])
+    if err != nil || pageSize <= 0 || pageSize > maxShowPageSize {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Page size between 1 and " + strconv.Itoa(maxShowPageSize))
+    }
+    bookmark := args[1]
+    excludeHouseFull := len(args) == 3 && strings.ToUpper(args[2]) == "TRUE"
+
+    resultsIterator, responseMetadata, err := stub.GetStateByPartialCompositeKeyWithPagination(domain.MovieTimeIndex, []string {}, int32(pageSize), bookmark)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    defer resultsIterator.Close()
+
+    showsList, err := readIndexedShows(stub, resultsIterator, excludeHouseFull)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    showPage := ShowPage {
+        Shows: showsList,
+        Bookmark: responseMetadata.Bookmark,
+        FetchedRecordsCount: int(responseMetadata.FetchedRecordsCount) }
+    showPageAsBytes, err := json.Marshal(showPage)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(showPageAsBytes)
+}
+
+// queryShows - Shows matching a Mango selector, a page at a time, through a CouchDB rich query. Args are the selector,
+// the page size and the bookmark of the previous page. The selector can only filter the fields of showQueryFields, the
+// Remaining Tickets are not among them as the stored count leaves out the tickets sold since the last compaction.
+func(t * MovieChaincode) queryShows(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Selector, Page size and Bookmark")
+    }
+    selector, err := domain.ParseSelector(args[0], showQueryFields)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    pageSize, err := strconv.Atoi(args[1])
+    if err != nil || pageSize <= 0 || pageSize > maxShowPageSize {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Page size between 1 and " + strconv.Itoa(maxShowPageSize))
+    }
+    bookmark := args[2]
+
+    query, err := selector.Query(domain.ShowDocType)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(query, int32(pageSize), bookmark)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    defer resultsIterator.Close()
+
+    showsList := []domain.Show{}
+    for resultsIterator.HasNext() {
+        queryResult, err := resultsIterator.Next()
+        if err != nil {
+            return domain.Failed(err)
+        }
+
+        show, err := domain.UnmarshalShow(queryResult.Value)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        err = deriveRemainingTickets(stub, show)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        showsList = append(showsList, *show)
+    }
+
+    showPage := ShowPage {
+        Shows: showsList,
+        Bookmark: responseMetadata.Bookmark,
+        FetchedRecordsCount: int(responseMetadata.FetchedRecordsCount) }
+    showPageAsBytes, err := json.Marshal(showPage)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(showPageAsBytes)
+}
+
+// readIndexedShows - Shows behind the indexMovieAndTime keys of an iterator, with their Remaining Tickets up to date
+func readIndexedShows(stub shim.ChaincodeStubInterface, resultsIterator shim.StateQueryIteratorInterface, excludeHouseFull bool) ([]domain.Show, error) {
+
+    showsList := []domain.Show{}
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return nil, err
+        }
+
+        _, compositeKeyParts, err := stub.SplitCompositeKey(responseRange.Key)
+        if err != nil {
+            return nil, err
+        }
+
+        show, err := getShow(stub, compositeKeyParts[0], compositeKeyParts[1])
+        if err != nil {
+            return nil, err
+        } else if show == nil {
+            continue
+        }
+
+        err = deriveRemainingTickets(stub, show)
+        if err != nil {
+            return nil, err
+        }
+        if excludeHouseFull && show.HouseFullFlag == "True" {
+            continue
+        }
+        showsList = append(showsList, *show)
+    }
+    return showsList, nil
+}
+
+// getShowHistory - Every version of a show record with the ID and time of the transaction that wrote it, for support
+// to reconstruct disputes. Arg is the Show ID. Allowed to box-office staff and theater admins. The submitter of a
+// version is in the block of its transaction. Tickets sold or released since the last compactShowTickets are ticket
+// count changes of their own and not in this history.
+func(t * MovieChaincode) getShowHistory(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID to fetch the history")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    err = domain.CheckStaff(stub, config.TheaterAdminMSP, domain.BoxOfficeRole, domain.TheaterAdminRole)
+    if err != nil {
+        return unauthorized("getShowHistory", err)
+    }
+
+    versions, err := domain.ShowHistory(stub, domain.ShowKey(movieId, timeSlot))
+    if err != nil {
+        return domain.Failed(err)
+    }
+    if len(versions) == 0 {
+        return domain.ErrorResponse(domain.CodeNotFound, "No show found for the requested Show ID: " + args[0])
+    }
+
+    versionsAsBytes, err := json.Marshal(versions)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(versionsAsBytes)
+}
+
+// getShowSeats - Seat inventory of a show with the status of every seat. Arg is the Show ID.
+func(t * MovieChaincode) getShowSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID to fetch the seats")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(domain.ShowSeatObject, []string {movieId, timeSlot})
+    if err != nil {
+        return domain.Failed(err)
+    }
+    defer resultsIterator.Close()
+
+    seatsList := []domain.Seat{}
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return domain.Failed(err)
+        }
+
+        seat, err := domain.UnmarshalSeat(responseRange.Value)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        seatsList = append(seatsList, *seat)
+    }
+
+    seatsListAsBytes, err := json.Marshal(seatsList)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    return shim.Success(seatsListAsBytes)
+}
+
+// reserveShowSeats - Books seats of a show for a Booking ID and lowers the Remaining Tickets of the show.
+// Args are Movie ID, Time slot, Booking ID, Number of Tickets and optionally the Seat Numbers to book;
+// without Seat Numbers the first free seats are taken. Fails without booking anything when a seat is taken.
+// Returns the domain.ShowQuote of the booked seats.
+func(t * MovieChaincode) reserveShowSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - reserveShowSeats ###########")
+
+    if len(args) < 4 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Booking ID, Number of Tickets and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    bookingId := args[2]
+    reqNmbrOfTickets, err := strconv.Atoi(args[3])
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting integer value for Number of Tickets")
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    reservedSeats, err := selectShowSeats(stub, show, reqNmbrOfTickets, args[4:])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    for i := range reservedSeats {
+        reservedSeats[i].Status = "Booked"
+        reservedSeats[i].BookingId = bookingId
+        reservedSeats[i].HeldUntil = ""
+        err = putSeat(stub, &reservedSeats[i])
+        if err != nil {
+            return domain.Failed(err)
+        }
+    }
+
+    // Recording the change of the Remaining Tickets of the show
+    err = putTicketDelta(stub, show, -len(reservedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    quoteAsBytes, err := json.Marshal(priceSeats(show, reservedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Seats reserved for Booking ID: ", bookingId, len(reservedSeats))
+    return shim.Success(quoteAsBytes)
+}
+
+// quoteShowSeats - Prices seats of a show without booking them. Args are the same as reserveShowSeats without
+// the Booking ID, and the same seats are picked as reserveShowSeats would book in this state of the ledger.
+func(t * MovieChaincode) quoteShowSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) < 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Number of Tickets and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    reqNmbrOfTickets, err := strconv.Atoi(args[2])
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting integer value for Number of Tickets")
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    quotedSeats, err := selectShowSeats(stub, show, reqNmbrOfTickets, args[3:])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    quoteAsBytes, err := json.Marshal(priceSeats(show, quotedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(quoteAsBytes)
+}
+
+// selectShowSeats - Free seats of a show for a booking: the requested Seat Numbers, or when none are requested the first
+// free seats side by side in a row, else the first free seats of the show. Errors when a seat does not exist, is not
+// free or is requested twice, or the show is cancelled.
+func selectShowSeats(stub shim.ChaincodeStubInterface, show *domain.Show, reqNmbrOfTickets int, requestedSeats []string) ([]domain.Seat, error) {
+
+    movieId := show.MovieId
+    timeSlot := show.AvailalbeTimeSlots
+    currTime, err := txTime(stub)
+    if err != nil {
+        return nil, err
+    }
+    if show.ShowStatus == "Cancelled" {
+        return nil, domain.NewError(domain.CodeConflict, "Show %s is cancelled", show.ShowId)
+    }
+    if reqNmbrOfTickets <= 0 {
+        return nil, domain.NewError(domain.CodeInvalidArgument, "Number of Tickets must be greater than zero")
+    }
+    if len(requestedSeats) > 0 && len(requestedSeats) != reqNmbrOfTickets {
+        return nil, domain.NewError(domain.CodeInvalidArgument, "Number of Tickets does not match the requested Seat Numbers")
+    }
+
+    // Picking the first free seats side by side when no Seat Numbers are requested, the same for a quote and the booking
+    // following it. Concurrent bookings picking the same seats conflict on the seat keys and one of them is resubmitted.
+    if len(requestedSeats) == 0 {
+        seatRows, err := showSeatRows(stub, show)
+        if err != nil {
+            return nil, err
+        }
+
+        selectedSeats := []domain.Seat{}
+        for _, seatNumbers := range seatRows {
+            adjacentSeats := []domain.Seat{}
+            for _, seatNumber := range seatNumbers {
+                seat, err := getSeat(stub, movieId, timeSlot, seatNumber)
+                if err != nil {
+                    return nil, err
+                } else if seat == nil || !seatIsFree(seat, currTime) {
+                    adjacentSeats = []domain.Seat{}
+                    continue
+                }
+
+                adjacentSeats = append(adjacentSeats, *seat)
+                if len(adjacentSeats) == reqNmbrOfTickets {
+                    return adjacentSeats, nil
+                }
+                if len(selectedSeats) < reqNmbrOfTickets {
+                    selectedSeats = append(selectedSeats, *seat)
+                }
+            }
+        }
+
+        // No row seats the party together, so it gets the first free seats of the show
+        if len(selectedSeats) == 0 {
+            return nil, domain.NewError(domain.CodeSoldOut, "%s at %s is sold out", movieId, timeSlot)
+        } else if len(selectedSeats) < reqNmbrOfTickets {
+            return nil, domain.NewError(domain.CodeInsufficientSeats, "Only %d seats are available for %s at %s", len(selectedSeats), movieId, timeSlot)
+        }
+        return selectedSeats, nil
+    }
+
+    selectedSeats := []domain.Seat{}
+    requested := map[string]bool{}
+    for _, seatNumber := range requestedSeats {
+        if requested[seatNumber] {
+            return nil, domain.NewError(domain.CodeInvalidArgument, "Seat %s is requested more than once for %s at %s", seatNumber, movieId, timeSlot)
+        }
+        requested[seatNumber] = true
+
+        seat, err := getSeat(stub, movieId, timeSlot, seatNumber)
+        if err != nil {
+            return nil, err
+        } else if seat == nil {
+            return nil, domain.NewError(domain.CodeNotFound, "Seat %s does not exist for %s at %s", seatNumber, movieId, timeSlot)
+        } else if !seatIsFree(seat, currTime) {
+            return nil, domain.NewError(domain.CodeConflict, "Seat %s is already taken for %s at %s", seatNumber, movieId, timeSlot)
+        }
+        selectedSeats = append(selectedSeats, *seat)
+    }
+    return selectedSeats, nil
+}
+
+// seatIsFree - Whether a seat can be sold at the given time, seats whose hold has expired count as free
+func seatIsFree(seat *domain.Seat, currTime time.Time) bool {
+
+    if seat.Status == "Free" {
+        return true
+    } else if seat.Status != "Held" {
+        return false
+    }
+    heldUntil, err := time.Parse(time.RFC3339Nano, seat.HeldUntil)
+    return err != nil || !currTime.Before(heldUntil)
+}
+
+// priceSeats - domain.ShowQuote of seats of a show from its price table, shows without a price table are free
+func priceSeats(show *domain.Show, seats []domain.Seat) *domain.ShowQuote {
+
+    quote := &domain.ShowQuote {
+        ShowId: show.ShowId,
+        MovieName: show.MovieName,
+        TimeSlot: show.AvailalbeTimeSlots,
+        TheaterId: show.TheaterId,
+        Seats: []domain.SeatQuote{} }
+    if quote.TheaterId == "" {
+        quote.TheaterId = defaultTheaterId
+    }
+
+    for _, seat := range seats {
+        price := 0
+        if show.PriceTable != nil {
+            categoryPrice, found := show.PriceTable.CategoryPrices[seat.Category]
+            if found {
+                price = categoryPrice
+            } else {
+                price = show.PriceTable.BasePrice
+            }
+        }
+        quote.Seats = append(quote.Seats, domain.SeatQuote{SeatNumber: seat.SeatNumber, Category: seat.Category, Price: price})
+        quote.TotalPrice = quote.TotalPrice + price
+    }
+    if show.PriceTable != nil {
+        quote.Currency = show.PriceTable.Currency
+    }
+    return quote
+}
+
+// releaseShowSeats - Frees the seats of a show booked for a Booking ID and raises the Remaining Tickets of the show.
+// Args are Movie ID, Time slot, Booking ID and the Seat Numbers; seats not booked for the Booking ID are left as they are.
+func(t * MovieChaincode) releaseShowSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - releaseShowSeats ###########")
+
+    if len(args) < 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Booking ID and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    bookingId := args[2]
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    releasedSeats := []domain.Seat{}
+    released := map[string]bool{}
+    for _, seatNumber := range args[3:] {
+        seat, err := getSeat(stub, movieId, timeSlot, seatNumber)
+        if err != nil {
+            return domain.Failed(err)
+        } else if seat == nil || seat.BookingId != bookingId || seat.Status != "Booked" || released[seatNumber] {
+            continue
+        }
+        released[seatNumber] = true
+
+        seat.Status = "Free"
+        seat.BookingId = ""
+        err = putSeat(stub, seat)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        releasedSeats = append(releasedSeats, *seat)
+    }
+
+    // Recording the change of the Remaining Tickets of the show
+    err = putTicketDelta(stub, show, len(releasedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    releasedSeatsAsBytes, err := json.Marshal(releasedSeats)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Seats released for Booking ID: ", bookingId, len(releasedSeats))
+    return shim.Success(releasedSeatsAsBytes)
+}
+
+// holdShowSeats - Holds seats of a show for a Hold ID until the given expiry time. Args are Movie ID, Time slot,
+// Hold ID, Held until (RFC 3339), Number of Tickets and optionally the Seat Numbers to hold. Held seats are not
+// sold to anyone else and are taken off the Remaining Tickets reported for the show until they expire.
+// Returns the domain.ShowQuote of the held seats.
+func(t * MovieChaincode) holdShowSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - holdShowSeats ###########")
+
+    if len(args) < 5 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Hold ID, Held until, Number of Tickets and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    holdId := args[2]
+    heldUntil, err := time.Parse(time.RFC3339Nano, args[3])
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting RFC 3339 time for Held until")
+    }
+    reqNmbrOfTickets, err := strconv.Atoi(args[4])
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting integer value for Number of Tickets")
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    heldSeats, err := selectShowSeats(stub, show, reqNmbrOfTickets, args[5:])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    for i := range heldSeats {
+        heldSeats[i].Status = "Held"
+        heldSeats[i].BookingId = holdId
+        heldSeats[i].HeldUntil = heldUntil.UTC().Format(time.RFC3339Nano)
+        err = putSeat(stub, &heldSeats[i])
+        if err != nil {
+            return domain.Failed(err)
+        }
+    }
+
+    quoteAsBytes, err := json.Marshal(priceSeats(show, heldSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Seats held for Hold ID: ", holdId, len(heldSeats))
+    return shim.Success(quoteAsBytes)
+}
+
+// confirmHeldSeats - Books the seats held for a Hold ID against a Booking ID and lowers the Remaining Tickets of the show.
+// Args are Movie ID, Time slot, Hold ID, Booking ID and the Seat Numbers. Fails without booking anything when one of
+// the seats is no longer held for the Hold ID or its hold has expired. Returns the domain.ShowQuote of the booked seats.
+func(t * MovieChaincode) confirmHeldSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - confirmHeldSeats ###########")
+
+    if len(args) < 5 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Hold ID, Booking ID and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    holdId := args[2]
+    bookingId := args[3]
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    } else if show.ShowStatus == "Cancelled" {
+        return domain.ErrorResponse(domain.CodeConflict, "Show " + show.ShowId + " is cancelled")
+    }
+
+    currTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    confirmedSeats := []domain.Seat{}
+    for _, seatNumber := range args[4:] {
+        seat, err := getSeat(stub, movieId, timeSlot, seatNumber)
+        if err != nil {
+            return domain.Failed(err)
+        } else if seat == nil || seat.Status != "Held" || seat.BookingId != holdId || seatIsFree(seat, currTime) {
+            return domain.ErrorResponse(domain.CodeConflict, "Seat " + seatNumber + " is no longer held for Hold ID " + holdId)
+        }
+
+        seat.Status = "Booked"
+        seat.BookingId = bookingId
+        seat.HeldUntil = ""
+        err = putSeat(stub, seat)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        confirmedSeats = append(confirmedSeats, *seat)
+    }
+
+    // Recording the change of the Remaining Tickets of the show
+    err = putTicketDelta(stub, show, -len(confirmedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    quoteAsBytes, err := json.Marshal(priceSeats(show, confirmedSeats))
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Held seats confirmed for Booking ID: ", bookingId, len(confirmedSeats))
+    return shim.Success(quoteAsBytes)
+}
+
+// releaseHeldSeats - Frees the seats of a show held for a Hold ID, whether or not the hold has expired.
+// Args are Movie ID, Time slot, Hold ID and the Seat Numbers; seats not held for the Hold ID are left as they are.
+func(t * MovieChaincode) releaseHeldSeats(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - releaseHeldSeats ###########")
+
+    if len(args) < 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Movie ID, Time Slot, Hold ID and Seat Numbers")
+    }
+    movieId := args[0]
+    timeSlot := args[1]
+    holdId := args[2]
+
+    releasedSeats := []domain.Seat{}
+    for _, seatNumber := range args[3:] {
+        seat, err := getSeat(stub, movieId, timeSlot, seatNumber)
+        if err != nil {
+            return domain.Failed(err)
+        } else if seat == nil || seat.Status != "Held" || seat.BookingId != holdId {
+            continue
+        }
+
+        seat.Status = "Free"
+        seat.BookingId = ""
+        seat.HeldUntil = ""
+        err = putSeat(stub, seat)
+        if err != nil {
+            return domain.Failed(err)
+        }
+        releasedSeats = append(releasedSeats, *seat)
+    }
+
+    releasedSeatsAsBytes, err := json.Marshal(releasedSeats)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Held seats released for Hold ID: ", holdId, len(releasedSeats))
+    return shim.Success(releasedSeatsAsBytes)
+}
+
+// putTicketDelta - Records a change of the Remaining Tickets of a show under the Transaction ID. Transactions booking
+// the same show write different keys and leave the show record alone, so they do not conflict with each other.
+func putTicketDelta(stub shim.ChaincodeStubInterface, show *domain.Show, change int) error {
+
+    if change == 0 {
+        return nil
+    }
+
+    delta := &ShowTicketDelta {
+        MovieId: show.MovieId,
+        TimeSlot: show.AvailalbeTimeSlots,
+        DeltaId: stub.GetTxID(),
+        Change: change }
+
+    deltaKey, err := stub.CreateCompositeKey(showTicketDeltaObject, []string{delta.MovieId, delta.TimeSlot, delta.DeltaId})
+    if err != nil {
+        return err
+    }
+
+    deltaAsBytes, err := json.Marshal(delta)
+    if err != nil {
+        return err
+    }
+    return stub.PutState(deltaKey, deltaAsBytes)
+}
+
+// deriveRemainingTickets - Adds the ticket count changes not yet compacted to the Remaining Tickets of a show, takes off
+// the seats held for a checkout and sets the House Full flag to match. Reads every change and seat of the show, so it is
+// kept out of the booking transactions.
+func deriveRemainingTickets(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    err := foldTicketDeltas(stub, show)
+    if err != nil {
+        return err
+    }
+
+    heldSeats, err := countHeldSeats(stub, show)
+    if err != nil {
+        return err
+    }
+    show.RemainingTickets = show.RemainingTickets - heldSeats
+    return setHouseFullFlag(show)
+}
+
+// countHeldSeats - Number of seats of a show held for a checkout whose hold has not expired
+func countHeldSeats(stub shim.ChaincodeStubInterface, show *domain.Show) (int, error) {
+
+    currTime, err := txTime(stub)
+    if err != nil {
+        return 0, err
+    }
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(domain.ShowSeatObject, []string{show.MovieId, show.AvailalbeTimeSlots})
+    if err != nil {
+        return 0, err
+    }
+    defer resultsIterator.Close()
+
+    heldSeats := 0
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return 0, err
+        }
+
+        seat, err := domain.UnmarshalSeat(responseRange.Value)
+        if err != nil {
+            return 0, err
+        }
+        if seat.Status == "Held" && !seatIsFree(seat, currTime) {
+            heldSeats = heldSeats + 1
+        }
+    }
+    return heldSeats, nil
+}
+
+// foldTicketDeltas - Adds the ticket count changes not yet compacted to the Remaining Tickets of a show and sets the
+// House Full flag to match. Held seats are left in, so the result can be stored on the show record.
+func foldTicketDeltas(stub shim.ChaincodeStubInterface, show *domain.Show) error {
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(showTicketDeltaObject, []string{show.MovieId, show.AvailalbeTimeSlots})
+    if err != nil {
+        return err
+    }
+    defer resultsIterator.Close()
+
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+
+        var delta ShowTicketDelta
+        err = json.Unmarshal(responseRange.Value, &delta)
+        if err != nil {
+            return err
+        }
+        show.RemainingTickets = show.RemainingTickets + delta.Change
+    }
+
+    return setHouseFullFlag(show)
+}
+
+// setHouseFullFlag - Sets the House Full flag of a show to match its Remaining Tickets. Errors when they fall outside
+// the capacity of the show, as its ticket count no longer adds up with the seats sold.
+func setHouseFullFlag(show *domain.Show) error {
+
+    if show.RemainingTickets < 0 || show.RemainingTickets > show.TotalTickets {
+        return domain.NewError(domain.CodeInternal, "Remaining Tickets of %s at %s add up to %d out of %d", show.MovieName, show.AvailalbeTimeSlots, show.RemainingTickets, show.TotalTickets)
+    }
+    if show.RemainingTickets == 0 {
+        show.HouseFullFlag = "True"
+    } else {
+        show.HouseFullFlag = "False"
+    }
+    return nil
+}
+
+// compactShowTickets - Folds the ticket count changes of a show into the show record and deletes them. Arg is the Show
+// ID. It rewrites the show record every booking of the show reads, so any booking in flight fails with an
+// MVCC conflict: run it only at quiet times when the show is not selling, e.g. from a scheduled job at night.
+func(t * MovieChaincode) compactShowTickets(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - compactShowTickets ###########")
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID to compact")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    resultsIterator, err := stub.GetStateByPartialCompositeKey(showTicketDeltaObject, []string{movieId, timeSlot})
+    if err != nil {
+        return domain.Failed(err)
+    }
+    defer resultsIterator.Close()
+
+    deltaKeys := []string{}
+    for resultsIterator.HasNext() {
+        responseRange, err := resultsIterator.Next()
+        if err != nil {
+            return domain.Failed(err)
+        }
+        deltaKeys = append(deltaKeys, responseRange.Key)
+    }
+
+    err = foldTicketDeltas(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    show.ModificationTime = modificationTime
+
+    err = putShow(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    for _, deltaKey := range deltaKeys {
+        err = stub.DelState(deltaKey)
+        if err != nil {
+            return domain.Failed(err)
+        }
+    }
+
+    logger.Info("Ticket changes compacted for ", movieId, timeSlot, len(deltaKeys))
+    return shim.Success(nil)
+}
+
+// initScreen - Creates or redefines a Screen from its layout. Args are Screen ID, Screen name, the rows as JSON, e.g.
+// [{"rowLabel":"A","seatsPerRow":12,"category":"Standard","aisles":[4,8],"blocked":[1]}], and optionally the Theater ID
+// A redefined layout only applies to the shows created after it.
+func(t * MovieChaincode) initScreen(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - initScreen ###########")
+
+    if len(args) != 3 && len(args) != 4 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Screen ID, Screen name, Rows and optionally Theater ID")
+    }
+    screenId := args[0]
+    screenName := args[1]
+    if screenId == "" {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Screen ID must not be empty")
+    }
+    theaterId := defaultTheaterId
+    if len(args) == 4 && args[3] != "" {
+        theaterId = args[3]
+    }
+
+    var rows []ScreenRow
+    err := json.Unmarshal([]byte(args[2]), &rows)
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Rows as a JSON array: " + err.Error())
+    } else if len(rows) == 0 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Screen must have at least one row")
+    }
+
+    // Validating the layout
+    rowLabels := map[string]bool{}
+    for i, row := range rows {
+        if row.RowLabel == "" || rowLabels[row.RowLabel] {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Row labels must be unique and not empty: " + row.RowLabel)
+        }
+        rowLabels[row.RowLabel] = true
+
+        if row.SeatsPerRow <= 0 {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Row " + row.RowLabel + " must have at least one seat")
+        }
+        if row.Category == "" {
+            rows[i].Category = "Standard"
+        }
+        for _, position := range append(row.Aisles, row.Blocked...) {
+            if position < 1 || position > row.SeatsPerRow {
+                return domain.ErrorResponse(domain.CodeInvalidArgument, "Position " + strconv.Itoa(position) + " is outside of row " + row.RowLabel)
+            }
+        }
+    }
+
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    screen := &Screen {
+        ScreenId: screenId,
+        ScreenName: screenName,
+        TheaterId: theaterId,
+        Rows: rows,
+        ModificationTime: modificationTime }
+
+    if len(layoutSeats(screen)) == 0 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Screen must have at least one seat that is not blocked")
+    }
+
+    err = putScreen(stub, screen)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    err = domain.SetEvent(stub, &domain.Event {
+        Message: "Screen layout saved succcessfully",
+        Screen: screenId,
+        TotalSeats: strconv.Itoa(screen.TotalSeats) })
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Screen layout saved successfully")
+    return shim.Success(nil)
+}
+
+// getScreen - Seat layout of a Screen
+func(t * MovieChaincode) getScreen(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    if len(args) != 1 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Screen ID to fetch the layout")
+    }
+    screenId := args[0]
+
+    screen, err := getScreenLayout(stub, screenId)
+    if err != nil {
+        return domain.Failed(err)
+    } else if screen == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Screen found for the requested Screen ID: " + screenId)
+    }
+
+    screenAsBytes, err := json.Marshal(screen)
+    if err != nil {
+        return domain.Failed(err)
+    }
+    return shim.Success(screenAsBytes)
+}
+
+// setShowPricing - Sets the price table of a show. Args are Show ID, Currency (ISO code such as INR), Base price and
+// the Category prices as JSON, e.g. {"Premium":25000,"Recliner":40000}; prices are in minor units.
+func(t * MovieChaincode) setShowPricing(stub shim.ChaincodeStubInterface, args[] string) pb.Response {
+
+    logger.Info("########### START - setShowPricing ###########")
+
+    if len(args) != 4 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Incorrect number of arguments. Expecting Show ID, Currency, Base price and Category prices")
+    }
+    movieId, timeSlot, err := domain.ParseShowId(args[0])
+    if err != nil {
+        return domain.Failed(err)
+    }
+    currency := strings.ToUpper(args[1])
+    if len(currency) != 3 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a three letter currency code")
+    }
+    basePrice, err := strconv.Atoi(args[2])
+    if err != nil || basePrice < 0 {
+        return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting a non negative integer value for Base price")
+    }
+
+    categoryPrices := map[string]int{}
+    if args[3] != "" {
+        err = json.Unmarshal([]byte(args[3]), &categoryPrices)
+        if err != nil {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Expecting Category prices as a JSON object: " + err.Error())
+        }
+    }
+    for category, price := range categoryPrices {
+        if price < 0 {
+            return domain.ErrorResponse(domain.CodeInvalidArgument, "Price of category " + category + " must not be negative")
+        }
+    }
+
+    show, err := getShow(stub, movieId, timeSlot)
+    if err != nil {
+        return domain.Failed(err)
+    } else if show == nil {
+        return domain.ErrorResponse(domain.CodeNotFound, "No Movie show of " + movieId + " is running for the requested time slot: " + timeSlot)
+    }
+
+    modificationTime, err := txTime(stub)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    show.PriceTable = &domain.PriceTable {
+        Currency: currency,
+        BasePrice: basePrice,
+        CategoryPrices: categoryPrices }
+    show.ModificationTime = modificationTime
+
+    err = putShow(stub, show)
+    if err != nil {
+        return domain.Failed(err)
+    }
+
+    logger.Info("Price table saved for ", movieId, timeSlot)
+    return shim.Success(nil)
+}
+
+// getMovieConfig - Configuration written by Init, with the Bookings chaincode defaulting to cc_bookings
+func getMovieConfig(stub shim.ChaincodeStubInterface) (*MovieConfig, error) {
+
+    config := &MovieConfig{BookingsChaincode: "cc_bookings", CleaningBufferMinutes: defaultCleaningBufferMinutes}
+
+    configAsBytes, err := stub.GetState(movieConfigKey)
+    if err != nil {
+        return nil, err
+    } else if configAsBytes != nil {
+        err = json.Unmarshal(configAsBytes, config)
+        if err != nil {
+            return nil, err
+        }
+    }
+    return config, nil
+}
+
+// putMovieConfig - Writes the chaincode configuration
+func putMovieConfig(stub shim.ChaincodeStubInterface, config *MovieConfig) error {
+
+    configAsBytes, err := json.Marshal(config)
+    if err != nil {
+        return err
+    }
+    return stub.PutState(movieConfigKey, configAsBytes)
+}
+
+// checkTheaterAdmin - Errors unless the caller belongs to the theater admin MSP or carries the theater admin role
+// attribute, see domain.CheckStaff
+func checkTheaterAdmin(stub shim.ChaincodeStubInterface) error {
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return err
+    }
+    return domain.CheckStaff(stub, config.TheaterAdminMSP, domain.TheaterAdminRole)
+}
+
+// invokeBookings - Calls the configured Bookings chaincode on the channel of this transaction
+func invokeBookings(stub shim.ChaincodeStubInterface, chainCodeArgs [][]byte) pb.Response {
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return domain.ErrorResponse(domain.CodeInternal, "Failed to read the chaincode configuration: " + err.Error())
+    }
+    return stub.InvokeChaincode(config.BookingsChaincode, chainCodeArgs, stub.GetChannelID())
+}
+
+// invokedThroughBookings - Whether the transaction proposal was sent to the Bookings chaincode, which is the case when
+// the Bookings chaincode calls this one for a booking. The caller's identity is the same either way.
+func invokedThroughBookings(stub shim.ChaincodeStubInterface) bool {
+
+    config, err := getMovieConfig(stub)
+    if err != nil {
+        return false
+    }
+    return domain.InvokedThrough(stub, config.BookingsChaincode)
+}
+
+// unauthorized - Error response with status 403 for a caller that may not call the function
+func unauthorized(function string, err error) pb.Response {
+
+    logger.Info("Unauthorized call of ", function, ": ", err.Error())
+    return domain.ErrorResponse(domain.CodeUnauthorized, "Not allowed to call " + function + ": " + err.Error())
+}
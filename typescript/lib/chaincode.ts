@@ -50,9 +50,13 @@ export async function installChaincode(
 
     const admin = await helper.getOrgAdmin(org);
 
+    // package the CouchDB index definitions when the chaincode has them
+    const metadataPath = path.join(process.env.GOPATH, 'src', chaincodePath, 'META-INF');
+
     const request = {
         targets: helper.newPeers(peers, org),
         chaincodePath,
+        metadataPath: fs.existsSync(metadataPath) ? metadataPath : undefined,
         chaincodeId: chaincodeName,
         chaincodeVersion,
         txId: client.newTransactionID(true)
@@ -0,0 +1,30 @@
+#!/bin/bash
+#
+# Copyright IBM Corp. All Rights Reserved.
+#
+# SPDX-License-Identifier: Apache-2.0
+#
+
+# The chaincodes are packaged from their own directory only, so each one carries a copy of the shared domain
+# package in its vendor directory. Run this after changing artifacts/src/github.com/chaincode/domain, it refreshes
+# the vendored copies and the chaincodes of the typescript sample, without their tests.
+
+cd "$(dirname "$0")"
+
+CHAINCODE_DIR="artifacts/src/github.com/chaincode"
+TS_CHAINCODE_DIR="typescript/artifacts/src/github.com/chaincode"
+
+for CC in movies bookings; do
+    VENDOR_DIR="$CHAINCODE_DIR/$CC/vendor/github.com/chaincode/domain"
+    echo "Vendoring the domain package into $CC"
+    rm -rf "$VENDOR_DIR"
+    mkdir -p "$VENDOR_DIR"
+    cp "$CHAINCODE_DIR"/domain/*.go "$VENDOR_DIR"/
+done
+
+for CC in domain movies bookings; do
+    echo "Copying $CC to the typescript sample"
+    rm -rf "$TS_CHAINCODE_DIR/$CC"
+    cp -R "$CHAINCODE_DIR/$CC" "$TS_CHAINCODE_DIR/$CC"
+    rm -f "$TS_CHAINCODE_DIR/$CC"/*_test.go
+done